@@ -0,0 +1,18 @@
+package robo
+
+// After returns a middleware handler which calls Next, and then calls fn
+// with the response's status code once the remaining handlers have
+// returned. It is useful for things like audit logging, or releasing
+// resources tied to the request. A response without an explicit status
+// is reported as a 200.
+//
+// If a handler panics, fn is not called.
+func After(fn func(r *Request, status int)) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		hw := newHookWriter(w, nil)
+		r.Next(hw.writer())
+
+		hw.commit(200)
+		fn(r, hw.status)
+	})
+}
@@ -0,0 +1,67 @@
+package robo
+
+import (
+	"testing"
+)
+
+func TestAfter(t *testing.T) {
+	var calls, status int
+	var body string
+
+	mux := NewMux()
+	mux.Use(After(func(r *Request, s int) {
+		calls++
+		status = s
+	}))
+	mux.Get("/ok", echo("/ok"))
+	mux.Get("/empty", func(w ResponseWriter, r *Request) {})
+	mux.Get("/gone", func(w ResponseWriter, r *Request) {
+		w.WriteHeader(410)
+	})
+
+	var tests = []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/ok", 200, "/ok"},
+		{"/empty", 200, ""},
+		{"/gone", 410, ""},
+	}
+
+	for _, test := range tests {
+		calls, status = 0, 0
+
+		w := serve(mux, "GET", test.path)
+		body = w.Body.String()
+
+		if calls != 1 || status != test.status || body != test.body {
+			t.Errorf("GET %s:", test.path)
+			t.Errorf("  got  %d calls, status %d, body %q", calls, status, body)
+			t.Errorf("  want 1 call, status %d, body %q", test.status, test.body)
+		}
+	}
+}
+
+func TestAfterHead(t *testing.T) {
+	var log []string
+
+	mux := NewMux()
+	mux.EnableStats()
+	mux.Use(After(func(r *Request, s int) {
+		log = append(log, r.Method+" "+PatternFromRequest(r))
+	}))
+	mux.Add("GET|HEAD", "/users/{id}", echo("/users/{id}"))
+
+	serve(mux, "GET", "/users/5")
+	serve(mux, "HEAD", "/users/6")
+
+	want := []string{"GET /users/{id}", "HEAD /users/{id}"}
+	if len(log) != len(want) || log[0] != want[0] || log[1] != want[1] {
+		t.Errorf("After log = %q, want %q", log, want)
+	}
+
+	if stat := mux.Stats()["/users/{id}"]; stat.Hits != 2 {
+		t.Errorf("Stats()[%q].Hits = %d, want 2", "/users/{id}", stat.Hits)
+	}
+}
@@ -0,0 +1,48 @@
+package robo
+
+import (
+	"net/http"
+	"strings"
+)
+
+// BearerKey is the data store key under which RequireBearer stores the
+// token of an authenticated request.
+const BearerKey = "robo.bearer"
+
+// BearerToken returns the token from a request's Authorization header, if
+// it uses the Bearer scheme (compared case-insensitively).
+func BearerToken(r *Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	if token = strings.TrimSpace(token); token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+// RequireBearer returns a middleware handler which only lets requests with
+// a bearer token accepted by validate through, storing the token in the
+// request's data store under BearerKey before calling Next. Requests with
+// a missing or rejected token receive a 401 response.
+func RequireBearer(validate func(token string) error) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		token, ok := BearerToken(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized.\n", 401)
+			return
+		}
+
+		if err := validate(token); err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			http.Error(w, "Unauthorized.\n", 401)
+			return
+		}
+
+		r.Set(BearerKey, token)
+		r.Next(w)
+	})
+}
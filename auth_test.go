@@ -0,0 +1,55 @@
+package robo
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+var requireBearerTests = []struct {
+	auth  string
+	code  int
+	token interface{}
+	www   string
+}{
+	{"Bearer good", 200, "good", ""},
+	{"bearer good", 200, "good", ""},
+	{"Bearer bad", 401, nil, `Bearer error="invalid_token"`},
+	{"", 401, nil, "Bearer"},
+	{"Bearer ", 401, nil, "Bearer"},
+	{"Basic Z29vZA==", 401, nil, "Bearer"},
+}
+
+func TestRequireBearer(t *testing.T) {
+	var token interface{}
+
+	mux := NewMux()
+	mux.Use(RequireBearer(func(token string) error {
+		if token != "good" {
+			return errors.New("bad token")
+		}
+		return nil
+	}))
+	mux.Get("/", func(w ResponseWriter, r *Request) {
+		token = r.Get(BearerKey)
+	})
+
+	for _, test := range requireBearerTests {
+		token = nil
+
+		hr := httptest.NewRequest("GET", "/", nil)
+		if test.auth != "" {
+			hr.Header.Set("Authorization", test.auth)
+		}
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, hr)
+
+		www := w.Header().Get("WWW-Authenticate")
+		if w.Code != test.code || token != test.token || www != test.www {
+			t.Errorf("GET / (Authorization %q):", test.auth)
+			t.Errorf("  got  %d, token %v, WWW-Authenticate %q", w.Code, token, www)
+			t.Errorf("  want %d, token %v, WWW-Authenticate %q", test.code, test.token, test.www)
+		}
+	}
+}
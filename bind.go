@@ -0,0 +1,168 @@
+package robo
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	errBindTarget = errors.New("robo: bind target must be a non-nil pointer to a struct")
+	errRequired   = errors.New("required value is missing")
+)
+
+// The BindError type describes a value which couldn't be converted to the
+// type of the struct field it was bound to.
+type BindError struct {
+	Field string // name of the struct field
+	Key   string // name of the parameter
+	Value string // the offending value
+	Err   error  // the conversion error
+}
+
+func (e *BindError) Error() string {
+	if e.Err == errRequired {
+		return fmt.Sprintf("robo: missing required value for %s", e.Key)
+	}
+	return fmt.Sprintf("robo: invalid value %q for %s: %v", e.Value, e.Key, e.Err)
+}
+
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+// BindParams copies the request's URL parameters, including those captured
+// by any parent Muxes, into the fields of the struct pointed to by v. Fields
+// are bound by their `param:"name"` tag, and parameters which weren't
+// captured leave their fields untouched, unless the tag includes the
+// "required" option (as in `param:"id,required"`).
+//
+// Fields may be strings, booleans, integers, floats, or implement
+// encoding.TextUnmarshaler. Values which can't be converted, and missing
+// required values, are reported as *BindError values joined into a single
+// error, while the remaining fields are still set.
+func BindParams(r *Request, v interface{}) error {
+	return bind(v, "param", func(name string) []string {
+		if s, ok := lookupParam(r, name); ok {
+			return []string{s}
+		}
+		return nil
+	})
+}
+
+// BindQuery copies the values of the request's querystring parameters into
+// the fields of the struct pointed to by v, like BindParams, using their
+// `query:"name"` tags. Slice fields receive all of a parameter's values,
+// while other fields receive the first one.
+func BindQuery(r *Request, v interface{}) error {
+	if r.query == nil {
+		r.query = r.URL.Query()
+	}
+	return bind(v, "query", func(name string) []string {
+		return r.query[name]
+	})
+}
+
+// bind sets tagged fields of the struct pointed to by v to the values
+// returned by lookup.
+func bind(v interface{}, tag string, lookup func(name string) []string) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errBindTarget
+	}
+
+	var errs []error
+
+	rv = rv.Elem()
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+
+		name, opts, _ := strings.Cut(sf.Tag.Get(tag), ",")
+		if name == "" || name == "-" || !sf.IsExported() {
+			continue
+		}
+
+		values := lookup(name)
+		if len(values) == 0 {
+			if opts == "required" {
+				errs = append(errs, &BindError{sf.Name, name, "", errRequired})
+			}
+			continue
+		}
+
+		f := rv.Field(i)
+		if f.Kind() == reflect.Slice && !isTextUnmarshaler(f) {
+			list := reflect.MakeSlice(f.Type(), len(values), len(values))
+			for j, s := range values {
+				if err := setField(list.Index(j), s); err != nil {
+					errs = append(errs, &BindError{sf.Name, name, s, err})
+				}
+			}
+			f.Set(list)
+			continue
+		}
+
+		if err := setField(f, values[0]); err != nil {
+			errs = append(errs, &BindError{sf.Name, name, values[0], err})
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// isTextUnmarshaler reports whether a field implements
+// encoding.TextUnmarshaler.
+func isTextUnmarshaler(f reflect.Value) bool {
+	_, ok := f.Addr().Interface().(encoding.TextUnmarshaler)
+	return ok
+}
+
+// setField converts s to the type of a struct field, and stores it there.
+func setField(f reflect.Value, s string) error {
+	if u, ok := f.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(s)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetUint(n)
+
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetFloat(n)
+
+	default:
+		return fmt.Errorf("unsupported field type %s", f.Type())
+	}
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package robo
+
+import (
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+type bindTarget struct {
+	Org    string  `param:"org"`
+	ID     int     `param:"id"`
+	Ratio  float64 `param:"ratio"`
+	Public bool    `param:"public"`
+	Other  string
+}
+
+func TestBindParams(t *testing.T) {
+	var got bindTarget
+	var err error
+
+	child := NewMux()
+	child.Get("/{id}", func(w ResponseWriter, r *Request) {
+		got = bindTarget{Other: "x"}
+		err = BindParams(r, &got)
+	})
+	child.Get("/{id}/{ratio}/{public}", func(w ResponseWriter, r *Request) {
+		got = bindTarget{}
+		err = BindParams(r, &got)
+	})
+
+	mux := NewMux()
+	mux.Host("{org}.example.com").Mount("/items", child)
+
+	var tests = []struct {
+		target string
+		want   bindTarget
+		keys   []string
+	}{
+		{"http://acme.example.com/items/42", bindTarget{"acme", 42, 0, false, "x"}, nil},
+		{"http://acme.example.com/items/42/0.5/true", bindTarget{"acme", 42, 0.5, true, ""}, nil},
+		{"http://acme.example.com/items/x/0.5/maybe", bindTarget{"acme", 0, 0.5, false, ""}, []string{"id", "public"}},
+	}
+
+	for _, test := range tests {
+		serve(mux, "GET", test.target)
+
+		var keys []string
+		for _, e := range unwrapJoined(err) {
+			var be *BindError
+			if !errors.As(e, &be) {
+				t.Errorf("GET %s: unexpected error %v", test.target, e)
+				continue
+			}
+			if !errors.Is(be, strconv.ErrSyntax) {
+				t.Errorf("GET %s: unexpected %s error %v", test.target, be.Key, be.Err)
+			}
+			keys = append(keys, be.Key)
+		}
+
+		if got != test.want || len(keys) != len(test.keys) {
+			goto fail
+		}
+		for i := range keys {
+			if keys[i] != test.keys[i] {
+				goto fail
+			}
+		}
+
+		continue
+
+	fail:
+		t.Errorf("GET %s:", test.target)
+		t.Errorf("  got  %+v (errors for %q)", got, keys)
+		t.Errorf("  want %+v (errors for %q)", test.want, test.keys)
+	}
+
+	if err := BindParams(&Request{}, got); err != errBindTarget {
+		t.Errorf("BindParams(non-pointer) = %v, want %v", err, errBindTarget)
+	}
+}
+
+// unwrapJoined returns the errors joined by errors.Join.
+func unwrapJoined(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if j, ok := err.(interface{ Unwrap() []error }); ok {
+		return j.Unwrap()
+	}
+	return []error{err}
+}
+
+type queryTarget struct {
+	Page  int      `query:"page,required"`
+	Sort  string   `query:"sort"`
+	Tags  []string `query:"tag"`
+	IDs   []int    `query:"id"`
+	Debug bool     `query:"debug"`
+}
+
+var bindQueryTests = []struct {
+	query string
+	want  queryTarget
+	keys  []string
+}{
+	{"page=2&sort=name", queryTarget{Page: 2, Sort: "name"}, nil},
+	{"page=1&tag=a&tag=b&id=1&id=2", queryTarget{Page: 1, Tags: []string{"a", "b"}, IDs: []int{1, 2}}, nil},
+	{"sort=name", queryTarget{Sort: "name"}, []string{"page"}},
+	{"page=x&id=1&id=y&debug=1", queryTarget{IDs: []int{1, 0}, Debug: true}, []string{"page", "id"}},
+}
+
+func TestBindQuery(t *testing.T) {
+	for _, test := range bindQueryTests {
+		r := &Request{Request: httptest.NewRequest("GET", "/?"+test.query, nil)}
+
+		var got queryTarget
+		err := BindQuery(r, &got)
+
+		var keys []string
+		for _, e := range unwrapJoined(err) {
+			var be *BindError
+			if !errors.As(e, &be) {
+				t.Errorf("BindQuery(%q): unexpected error %v", test.query, e)
+				continue
+			}
+			keys = append(keys, be.Key)
+		}
+
+		if fmt.Sprint(got) != fmt.Sprint(test.want) || fmt.Sprint(keys) != fmt.Sprint(test.keys) {
+			t.Errorf("BindQuery(%q):", test.query)
+			t.Errorf("  got  %+v (errors for %q)", got, keys)
+			t.Errorf("  want %+v (errors for %q)", test.want, test.keys)
+		}
+	}
+}
+
+func TestBindErrorMessage(t *testing.T) {
+	r := &Request{Request: httptest.NewRequest("GET", "/?id=x", nil)}
+
+	var v struct {
+		Page int `query:"page,required"`
+		ID   int `query:"id"`
+	}
+
+	want := "robo: missing required value for page\n" +
+		`robo: invalid value "x" for id: strconv.ParseInt: parsing "x": invalid syntax`
+	if err := BindQuery(r, &v); err == nil || err.Error() != want {
+		t.Errorf("BindQuery:")
+		t.Errorf("  got  %v", err)
+		t.Errorf("  want %s", want)
+	}
+}
@@ -0,0 +1,251 @@
+package robo
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RequireContentType returns a middleware handler which responds with a
+// 415 to requests carrying a body with a Content-Type other than the
+// listed media types (compared without parameters like charset). Entries
+// of the form "type/*" match any subtype. Requests without a body are
+// passed on unchecked.
+func RequireContentType(types ...string) Handler {
+	allowed := make([]string, len(types))
+	for i, t := range types {
+		allowed[i] = strings.ToLower(t)
+	}
+
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		if !hasBody(r.Request) {
+			r.Next(w)
+			return
+		}
+
+		mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err == nil {
+			for _, t := range allowed {
+				if t == mt || (strings.HasSuffix(t, "/*") && strings.HasPrefix(mt, t[:len(t)-1])) {
+					r.Next(w)
+					return
+				}
+			}
+		}
+
+		http.Error(w, "Unsupported media type.\n", 415)
+	})
+}
+
+// DecompressRequest returns a middleware handler which transparently
+// decompresses the bodies of requests with a "Content-Encoding: gzip"
+// header, removing the header (and Content-Length) before calling Next.
+// Requests whose body doesn't start with a valid gzip header are rejected
+// with a 400; corruption further into the body surfaces as read errors.
+func DecompressRequest() Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		if !strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") || !hasBody(r.Request) {
+			r.Next(w)
+			return
+		}
+
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, "Bad request.\n", 400)
+			return
+		}
+
+		r.Body = &gzipBody{zr, r.Body}
+		r.ContentLength = -1
+		r.Header.Del("Content-Encoding")
+		r.Header.Del("Content-Length")
+
+		r.Next(w)
+	})
+}
+
+// gzipBody decompresses a request body.
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (b *gzipBody) Close() error {
+	b.Reader.Close()
+	return b.body.Close()
+}
+
+// HasBody returns a MatcherFunc which requires requests to carry a body
+// (as indicated by their Content-Length or Transfer-Encoding headers), or,
+// if want is false, to not carry one.
+func HasBody(want bool) MatcherFunc {
+	return func(r *http.Request) bool {
+		return hasBody(r) == want
+	}
+}
+
+// hasBody reports whether a request carries a body.
+func hasBody(r *http.Request) bool {
+	if r.Body == nil || r.Body == http.NoBody {
+		return false
+	}
+	return r.ContentLength != 0 || len(r.TransferEncoding) > 0
+}
+
+// Negotiate picks the media type from offers which best matches the
+// request's Accept header, taking quality values and the specificity of
+// media ranges into account. Ties go to the earliest offer. If the request
+// has no Accept header the first offer is returned, and if none of the
+// offers are acceptable the result is "".
+func Negotiate(r *Request, offers ...string) string {
+	accept := r.Header.Values("Accept")
+	if len(accept) == 0 {
+		if len(offers) == 0 {
+			return ""
+		}
+		return offers[0]
+	}
+
+	var ranges []mediaRange
+	for _, v := range accept {
+		for _, part := range strings.Split(v, ",") {
+			if mr, ok := parseMediaRange(part); ok {
+				ranges = append(ranges, mr)
+			}
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		if q := acceptQuality(ranges, strings.ToLower(offer)); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+
+	return best
+}
+
+// NotFoundNegotiated returns a handler for use with NotFound, which picks
+// one of renderers based on the request's Accept header (see Negotiate).
+// The renderers are keyed by media type, and are responsible for sending
+// the 404 status themselves. If none of them are acceptable, a plain 404
+// response is sent.
+func NotFoundNegotiated(renderers map[string]Handler) Handler {
+	offers := make([]string, 0, len(renderers))
+	for t := range renderers {
+		offers = append(offers, t)
+	}
+	sort.Strings(offers)
+
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		addVary(w.Header(), "Accept")
+
+		if t := Negotiate(r, offers...); t != "" {
+			renderers[t].ServeRoboHTTP(w, r)
+			return
+		}
+
+		http.Error(w, "Not found.\n", 404)
+	})
+}
+
+// AddByAccept registers a route like Add, with one handler per media type
+// it can produce. When the route matches, the handler for the media type
+// which best matches the request's Accept header is invoked (see
+// Negotiate), with the response's Content-Type set to that media type
+// unless it has already been set. If none of the media types are
+// acceptable, a plain 406 response is sent.
+func (m *Mux) AddByAccept(method, pattern string, handlers map[string]interface{}) *Route {
+	if len(handlers) == 0 {
+		panic(errNoHandlers)
+	}
+
+	offers := make([]string, 0, len(handlers))
+	for t := range handlers {
+		offers = append(offers, t)
+	}
+	sort.Strings(offers)
+
+	byType := make(map[string]Handler, len(handlers))
+	for t, h := range handlers {
+		clean, err := adaptHandlers([]interface{}{h})
+		if err != nil {
+			panic(err)
+		}
+		byType[t] = clean[0]
+	}
+
+	return m.Add(method, pattern, func(w ResponseWriter, r *Request) {
+		h := w.Header()
+		addVary(h, "Accept")
+
+		t := Negotiate(r, offers...)
+		if t == "" {
+			http.Error(w, "Not acceptable.\n", 406)
+			return
+		}
+
+		if h.Get("Content-Type") == "" {
+			h.Set("Content-Type", t)
+		}
+		byType[t].ServeRoboHTTP(w, r)
+	})
+}
+
+// A mediaRange is a parsed element of an Accept header.
+type mediaRange struct {
+	typ, sub string
+	q        float64
+}
+
+// parseMediaRange parses a media range like "text/*;q=0.5".
+func parseMediaRange(s string) (mediaRange, bool) {
+	s, params, _ := strings.Cut(s, ";")
+	typ, sub, ok := strings.Cut(strings.ToLower(strings.TrimSpace(s)), "/")
+	if !ok || typ == "" || sub == "" || typ == "*" && sub != "*" {
+		return mediaRange{}, false
+	}
+
+	mr := mediaRange{typ, sub, 1}
+	for _, p := range strings.Split(params, ";") {
+		k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+		if strings.EqualFold(k, "q") {
+			if q, err := strconv.ParseFloat(v, 64); err == nil && q >= 0 && q <= 1 {
+				mr.q = q
+			}
+		}
+	}
+
+	return mr, true
+}
+
+// acceptQuality returns the quality value of the most specific media range
+// matching a media type.
+func acceptQuality(ranges []mediaRange, offer string) float64 {
+	typ, sub, _ := strings.Cut(offer, "/")
+
+	q, specificity := 0.0, 0
+	for _, mr := range ranges {
+		var n int
+		switch {
+		case mr.typ == typ && mr.sub == sub:
+			n = 3
+		case mr.typ == typ && mr.sub == "*":
+			n = 2
+		case mr.typ == "*":
+			n = 1
+		default:
+			continue
+		}
+
+		if n > specificity {
+			q, specificity = mr.q, n
+		}
+	}
+
+	return q
+}
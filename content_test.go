@@ -0,0 +1,243 @@
+package robo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var requireContentTypeTests = []struct {
+	method string
+	ctype  string
+	body   string
+	code   int
+}{
+	{"POST", "application/json", "{}", 200},
+	{"POST", "application/JSON; charset=utf-8", "{}", 200},
+	{"POST", "text/plain; charset=utf-8", "hello", 200},
+	{"POST", "application/xml", "<x/>", 415},
+	{"POST", "", "hello", 415},
+	{"POST", "", "", 200},
+	{"GET", "", "", 200},
+	{"GET", "application/xml", "", 200},
+}
+
+func TestRequireContentType(t *testing.T) {
+	for _, test := range requireContentTypeTests {
+		mux := NewMux()
+		mux.Any("*", RequireContentType("application/json", "text/*"))
+		mux.Any("/", echo("/"))
+
+		hr := httptest.NewRequest(test.method, "/", strings.NewReader(test.body))
+		if test.ctype != "" {
+			hr.Header.Set("Content-Type", test.ctype)
+		}
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, hr)
+
+		if w.Code != test.code {
+			t.Errorf("RequireContentType, %s %q (%d bytes):", test.method, test.ctype, len(test.body))
+			t.Errorf("  got  %d", w.Code)
+			t.Errorf("  want %d", test.code)
+		}
+	}
+}
+
+var negotiateTests = []struct {
+	accept string
+	want   string
+}{
+	{"", "application/json"},
+	{"text/html", "text/html"},
+	{"application/json, text/html", "application/json"},
+	{"text/html;q=0.9, application/json;q=0.8", "text/html"},
+	{"text/*", "text/html"},
+	{"*/*;q=0.1, text/html;q=0", "application/json"},
+	{"image/png", ""},
+	{"garbage", ""},
+}
+
+func TestNegotiate(t *testing.T) {
+	for _, test := range negotiateTests {
+		r := &Request{Request: httptest.NewRequest("GET", "/", nil)}
+		if test.accept != "" {
+			r.Header.Set("Accept", test.accept)
+		}
+
+		if got := Negotiate(r, "application/json", "text/html"); got != test.want {
+			t.Errorf("Negotiate(Accept %q) = %q, want %q", test.accept, got, test.want)
+		}
+	}
+}
+
+func TestNotFoundNegotiated(t *testing.T) {
+	mux := NewMux()
+	mux.NotFound(NotFoundNegotiated(map[string]Handler{
+		"application/json": HandlerFunc(func(w ResponseWriter, r *Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(404)
+			w.Write([]byte(`{"error":"not found"}`))
+		}),
+		"text/html": HandlerFunc(func(w ResponseWriter, r *Request) {
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(404)
+			w.Write([]byte("<h1>Not found</h1>"))
+		}),
+	}))
+
+	var tests = []struct {
+		accept string
+		ctype  string
+		body   string
+	}{
+		{"application/json", "application/json", `{"error":"not found"}`},
+		{"text/html", "text/html", "<h1>Not found</h1>"},
+		{"image/png", "text/plain; charset=utf-8", "Not found.\n\n"},
+	}
+
+	for _, test := range tests {
+		hr := httptest.NewRequest("GET", "/missing", nil)
+		hr.Header.Set("Accept", test.accept)
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, hr)
+
+		ctype := w.Header().Get("Content-Type")
+		if w.Code != 404 || ctype != test.ctype || w.Body.String() != test.body {
+			t.Errorf("GET /missing (Accept %q):", test.accept)
+			t.Errorf("  got  %d %q %q", w.Code, ctype, w.Body.String())
+			t.Errorf("  want 404 %q %q", test.ctype, test.body)
+		}
+	}
+}
+
+func TestAddByAccept(t *testing.T) {
+	mux := NewMux()
+	mux.AddByAccept("GET", "/users/{id}", map[string]interface{}{
+		"application/json": func(w ResponseWriter, r *Request) {
+			fmt.Fprintf(w, `{"id":%q}`, r.Param("id"))
+		},
+		"text/html": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte("<p>user</p>"))
+		},
+	})
+
+	var tests = []struct {
+		accept string
+		code   int
+		ctype  string
+		body   string
+	}{
+		{"application/json", 200, "application/json", `{"id":"5"}`},
+		{"text/html, application/json;q=0.5", 200, "text/html; charset=utf-8", "<p>user</p>"},
+		{"*/*", 200, "application/json", `{"id":"5"}`},
+		{"", 200, "application/json", `{"id":"5"}`},
+		{"image/png", 406, "text/plain; charset=utf-8", "Not acceptable.\n\n"},
+	}
+
+	for _, test := range tests {
+		hr := httptest.NewRequest("GET", "/users/5", nil)
+		if test.accept != "" {
+			hr.Header.Set("Accept", test.accept)
+		}
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, hr)
+
+		ctype := w.Header().Get("Content-Type")
+		if w.Code != test.code || ctype != test.ctype || w.Body.String() != test.body {
+			t.Errorf("GET /users/5 (Accept %q):", test.accept)
+			t.Errorf("  got  %d %q %q", w.Code, ctype, w.Body.String())
+			t.Errorf("  want %d %q %q", test.code, test.ctype, test.body)
+		}
+		if v := w.Header().Get("Vary"); v != "Accept" {
+			t.Errorf("GET /users/5 (Accept %q): Vary = %q, want %q", test.accept, v, "Accept")
+		}
+	}
+}
+
+func TestHasBody(t *testing.T) {
+	mux := NewMux()
+	mux.Post("/items", echo("with body")).Match(HasBody(true))
+	mux.Post("/items", echo("without body")).Match(HasBody(false))
+
+	var tests = []struct {
+		body    string
+		chunked bool
+		want    string
+	}{
+		{`{"name":"x"}`, false, "with body"},
+		{`{"name":"x"}`, true, "with body"},
+		{"", false, "without body"},
+	}
+
+	for _, test := range tests {
+		hr := httptest.NewRequest("POST", "/items", strings.NewReader(test.body))
+		if test.chunked {
+			hr.ContentLength = -1
+			hr.TransferEncoding = []string{"chunked"}
+		}
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, hr)
+
+		if w.Body.String() != test.want {
+			t.Errorf("POST /items (%d bytes, chunked %v):", len(test.body), test.chunked)
+			t.Errorf("  got  %q", w.Body.String())
+			t.Errorf("  want %q", test.want)
+		}
+	}
+}
+
+func TestDecompressRequest(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write([]byte("hello, world"))
+	zw.Close()
+
+	mux := NewMux()
+	mux.Use(DecompressRequest())
+	mux.Post("/", func(w ResponseWriter, r *Request) {
+		buf, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		w.Write([]byte(r.Header.Get("Content-Encoding") + string(buf)))
+	})
+
+	var tests = []struct {
+		encoding string
+		body     string
+		code     int
+		want     string
+	}{
+		{"gzip", compressed.String(), 200, "hello, world"},
+		{"GZIP", compressed.String(), 200, "hello, world"},
+		{"", "plain", 200, "plain"},
+		{"gzip", "not gzip at all", 400, "Bad request.\n\n"},
+	}
+
+	for _, test := range tests {
+		hr := httptest.NewRequest("POST", "/", strings.NewReader(test.body))
+		if test.encoding != "" {
+			hr.Header.Set("Content-Encoding", test.encoding)
+		}
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, hr)
+
+		if w.Code != test.code || w.Body.String() != test.want {
+			t.Errorf("POST / (Content-Encoding %q):", test.encoding)
+			t.Errorf("  got  %d %q", w.Code, w.Body.String())
+			t.Errorf("  want %d %q", test.code, test.want)
+		}
+	}
+}
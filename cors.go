@@ -0,0 +1,142 @@
+package robo
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOptions configures the CORS middleware.
+type CORSOptions struct {
+	// AllowedOrigins lists the origins (like "https://example.com")
+	// allowed to make cross-origin requests. The entry "*" allows any
+	// origin.
+	AllowedOrigins []string
+
+	// AllowedMethods lists the methods allowed in cross-origin requests.
+	// It defaults to GET, HEAD and POST.
+	AllowedMethods []string
+
+	// AllowedHeaders lists the request headers allowed in cross-origin
+	// requests, beyond the CORS-safelisted ones.
+	AllowedHeaders []string
+
+	// AllowCredentials lets cross-origin requests include credentials,
+	// like cookies. It can't be combined with the "*" origin, since that
+	// would let any site make authenticated requests on a user's behalf.
+	AllowCredentials bool
+
+	// MaxAge is how long clients may cache the results of a preflight
+	// request. Zero leaves it up to the client.
+	MaxAge time.Duration
+}
+
+// CORS returns a middleware handler implementing Cross-Origin Resource
+// Sharing. Requests from allowed origins receive the appropriate response
+// headers before Next is called, while preflight requests (OPTIONS
+// requests with an Access-Control-Request-Method header) from allowed
+// origins are answered with a 204 right away. Requests from other origins
+// are passed on unchanged. CORS panics if opts.AllowCredentials is set
+// but opts.AllowedOrigins includes "*".
+func CORS(opts CORSOptions) Handler {
+	if opts.AllowCredentials && allowedOrigin(opts.AllowedOrigins, "*") {
+		panic("robo: CORS credentials require explicit origins")
+	}
+
+	methods := opts.AllowedMethods
+	if len(methods) == 0 {
+		methods = []string{"GET", "HEAD", "POST"}
+	}
+
+	allowMethods := strings.Join(methods, ", ")
+	allowHeaders := strings.Join(opts.AllowedHeaders, ", ")
+
+	var maxAge string
+	if opts.MaxAge > 0 {
+		maxAge = strconv.FormatInt(int64(opts.MaxAge/time.Second), 10)
+	}
+
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		h := w.Header()
+		addVary(h, "Origin")
+
+		origin := r.Header.Get("Origin")
+		if origin == "" || !allowedOrigin(opts.AllowedOrigins, origin) {
+			r.Next(w)
+			return
+		}
+
+		if opts.AllowCredentials {
+			h.Set("Access-Control-Allow-Origin", origin)
+			h.Set("Access-Control-Allow-Credentials", "true")
+		} else if allowedOrigin(opts.AllowedOrigins, "*") {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+
+		if r.Method != "OPTIONS" || r.Header.Get("Access-Control-Request-Method") == "" {
+			r.Next(w)
+			return
+		}
+
+		addVary(h, "Access-Control-Request-Method", "Access-Control-Request-Headers")
+		h.Set("Access-Control-Allow-Methods", allowMethods)
+		if allowHeaders != "" {
+			h.Set("Access-Control-Allow-Headers", allowHeaders)
+		}
+		if maxAge != "" {
+			h.Set("Access-Control-Max-Age", maxAge)
+		}
+
+		w.WriteHeader(204)
+	})
+}
+
+// allowedOrigin reports whether origin is in the list of allowed origins.
+func allowedOrigin(allowed []string, origin string) bool {
+	for _, o := range allowed {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// EnableCORS installs the CORS middleware for all of the Mux's routes, and
+// registers a catch-all OPTIONS route so that preflight requests reach it
+// even for paths without OPTIONS routes of their own. Cross-origin
+// preflight requests are always answered by the CORS middleware.
+//
+// Other OPTIONS requests to paths without an OPTIONS route receive a 204
+// response with an Allow header listing the methods registered for the
+// path, or fall through to the NotFound handlers if there are none.
+func (m *Mux) EnableCORS(opts CORSOptions) {
+	m.Use(CORS(opts))
+	options := HandlerFunc(func(w ResponseWriter, r *Request) {
+		allowed := m.allowed(r.queue.table, r.Request, r.queue.path)
+		if len(allowed) == 0 {
+			r.Next(w)
+			return
+		}
+
+		allowed = append(allowed, "OPTIONS")
+		sort.Strings(allowed)
+
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		w.WriteHeader(204)
+	})
+
+	route, err := newRoute("OPTIONS", "*", []Handler{options})
+	if err != nil {
+		panic(err)
+	}
+
+	// the route matches every path, so it mustn't count towards the
+	// methods allowed for them
+	route.priority = math.MinInt
+	route.hidden = true
+	m.insert(route)
+}
@@ -0,0 +1,101 @@
+package robo
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var corsTests = []struct {
+	method  string
+	path    string
+	origin  string
+	request string
+	code    int
+	acao    string
+	methods string
+	allow   string
+}{
+	// cross-origin preflight
+	{"OPTIONS", "/items", "https://app.example", "PUT", 204, "https://app.example", "GET, PUT", ""},
+	{"OPTIONS", "/custom", "https://app.example", "PUT", 204, "https://app.example", "GET, PUT", ""},
+	{"OPTIONS", "/items", "https://evil.example", "PUT", 204, "", "", "GET, OPTIONS, PUT"},
+
+	// same-origin OPTIONS
+	{"OPTIONS", "/items", "", "", 204, "", "", "GET, OPTIONS, PUT"},
+	{"OPTIONS", "/custom", "", "", 200, "", "", ""},
+	{"OPTIONS", "/missing", "", "", 404, "", "", ""},
+
+	// simple requests
+	{"GET", "/items", "https://app.example", "", 200, "https://app.example", "", ""},
+	{"GET", "/items", "https://evil.example", "", 200, "", "", ""},
+	{"GET", "/items", "", "", 200, "", "", ""},
+
+	// other methods
+	{"GET", "/missing", "", "", 404, "", "", ""},
+	{"DELETE", "/items", "", "", 405, "", "", "GET, PUT"},
+}
+
+func TestEnableCORS(t *testing.T) {
+	mux := NewMux()
+	mux.EnableCORS(CORSOptions{
+		AllowedOrigins: []string{"https://app.example"},
+		AllowedMethods: []string{"GET", "PUT"},
+		MaxAge:         time.Hour,
+	})
+	mux.Add("GET|PUT", "/items", echo("/items"))
+	mux.Add("OPTIONS", "/custom", echo("/custom"))
+
+	for _, test := range corsTests {
+		hr := httptest.NewRequest(test.method, test.path, nil)
+		if test.origin != "" {
+			hr.Header.Set("Origin", test.origin)
+		}
+		if test.request != "" {
+			hr.Header.Set("Access-Control-Request-Method", test.request)
+		}
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, hr)
+
+		h := w.Header()
+		if w.Code != test.code || h.Get("Access-Control-Allow-Origin") != test.acao ||
+			h.Get("Access-Control-Allow-Methods") != test.methods || h.Get("Allow") != test.allow {
+			t.Errorf("%s %s (Origin %q, requesting %q):", test.method, test.path, test.origin, test.request)
+			t.Errorf("  got  %d, origin %q, methods %q, Allow %q", w.Code,
+				h.Get("Access-Control-Allow-Origin"), h.Get("Access-Control-Allow-Methods"), h.Get("Allow"))
+			t.Errorf("  want %d, origin %q, methods %q, Allow %q", test.code, test.acao, test.methods, test.allow)
+		}
+	}
+}
+
+func TestCORSCredentials(t *testing.T) {
+	mux := NewMux()
+	mux.Use(CORS(CORSOptions{
+		AllowedOrigins:   []string{"https://app.example"},
+		AllowCredentials: true,
+	}))
+	mux.Get("/", func(w ResponseWriter, r *Request) {})
+
+	for _, origin := range []string{"https://app.example", "https://evil.example"} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+
+		want := ""
+		if origin == "https://app.example" {
+			want = "true"
+		}
+		if got := w.Header().Get("Access-Control-Allow-Credentials"); got != want {
+			t.Errorf("Origin %s: got credentials %q, want %q", origin, got, want)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("CORS with credentials and the \"*\" origin didn't panic")
+		}
+	}()
+	CORS(CORSOptions{AllowedOrigins: []string{"*"}, AllowCredentials: true})
+}
@@ -0,0 +1,57 @@
+package robo
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// The StatusCoder interface is implemented by errors which map to a
+// particular HTTP status code.
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// The Error type is an error carrying an HTTP status code. When panicked
+// with, the default OnPanic handler responds with its status and message
+// rather than a 500, which makes it usable as a shortcut out of deeply
+// nested handler code.
+type Error struct {
+	Status  int
+	Message string
+}
+
+// NewError creates an Error with the given status code and message.
+func NewError(status int, message string) *Error {
+	return &Error{status, message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// StatusCode returns the error's status code.
+func (e *Error) StatusCode() int {
+	return e.Status
+}
+
+// Problem responds with an RFC 7807 problem details object, encoded as
+// JSON with the "application/problem+json" media type. The object's type
+// is "about:blank", and an empty title defaults to the status code's
+// standard description. An empty detail is omitted.
+func Problem(w ResponseWriter, status int, title, detail string) {
+	if title == "" {
+		title = http.StatusText(status)
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "application/problem+json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+
+	json.NewEncoder(w).Encode(struct {
+		Type   string `json:"type"`
+		Title  string `json:"title"`
+		Status int    `json:"status"`
+		Detail string `json:"detail,omitempty"`
+	}{"about:blank", title, status, detail})
+}
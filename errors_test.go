@@ -0,0 +1,70 @@
+package robo
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProblem(t *testing.T) {
+	w := httptest.NewRecorder()
+	Problem(w, 404, "", "No user with ID 5.")
+
+	if w.Code != 404 {
+		t.Errorf("status = %d, want 404", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("Content-Type = %q, want %q", ct, "application/problem+json")
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("invalid body %q: %v", w.Body.String(), err)
+	}
+
+	want := map[string]interface{}{
+		"type":   "about:blank",
+		"title":  "Not Found",
+		"status": 404.0,
+		"detail": "No user with ID 5.",
+	}
+	if len(got) != len(want) {
+		t.Errorf("body has fields %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("body[%q] = %v, want %v", k, got[k], v)
+		}
+	}
+}
+
+func TestProblemJSON(t *testing.T) {
+	mux := NewMux()
+	mux.OnPanic(nil)
+	mux.ProblemJSON(true)
+	mux.Get("/error", func(w ResponseWriter, r *Request) {
+		panic(NewError(409, "Already exists."))
+	})
+	mux.Get("/string", func(w ResponseWriter, r *Request) {
+		panic("oops")
+	})
+
+	var tests = []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/error", 409, `{"type":"about:blank","title":"Conflict","status":409,"detail":"Already exists."}` + "\n"},
+		{"/string", 500, `{"type":"about:blank","title":"Internal Server Error","status":500}` + "\n"},
+	}
+
+	for _, test := range tests {
+		w := serve(mux, "GET", test.path)
+		ct := w.Header().Get("Content-Type")
+		if w.Code != test.code || ct != "application/problem+json" || w.Body.String() != test.body {
+			t.Errorf("GET %s:", test.path)
+			t.Errorf("  got  %d %q %s", w.Code, ct, w.Body.String())
+			t.Errorf("  want %d %q %s", test.code, "application/problem+json", test.body)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package robo
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FileServerOptions configures a FileServer created by NewFileServer.
+type FileServerOptions struct {
+	// ListDirectories enables listings for directories without an
+	// index.html file.
+	ListDirectories bool
+}
+
+// FileServer returns a handler serving files from root. The file's path is
+// taken from the route's wildcard, so the handler should be registered
+// with a pattern like "/static/*". Requests for a directory are served its
+// index.html file, if it has one. Paths which don't refer to a regular
+// file (or a directory with an index), or which contain empty, "." or ".."
+// segments, are passed on by calling Next.
+//
+// Files are served with http.ServeContent, which takes care of Range
+// requests, conditional requests and the Content-Type header.
+func FileServer(root http.FileSystem) Handler {
+	return NewFileServer(root, FileServerOptions{})
+}
+
+// NewFileServer returns a handler serving files from root, like FileServer,
+// with additional options.
+func NewFileServer(root http.FileSystem, opts FileServerOptions) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		// directories may be requested with a trailing slash
+		value := r.Param("*")
+		trailing := strings.HasSuffix(value, "/")
+
+		segments := splitSegments(strings.TrimSuffix(value, "/"))
+		if segments == nil {
+			r.Next(w)
+			return
+		}
+
+		name := "/" + strings.Join(segments, "/")
+
+		f, err := root.Open(name)
+		if err != nil {
+			r.Next(w)
+			return
+		}
+		defer f.Close()
+
+		fi, err := f.Stat()
+		if err != nil || !fi.IsDir() {
+			if trailing {
+				r.Next(w)
+				return
+			}
+			serveContent(w, r, f)
+			return
+		}
+
+		// look for an index file before falling back on a listing
+		if index, err := root.Open(path.Join(name, "index.html")); err == nil {
+			defer index.Close()
+			if fi, err := index.Stat(); err == nil && fi.Mode().IsRegular() {
+				http.ServeContent(w, r.Request, fi.Name(), fi.ModTime(), index)
+				return
+			}
+		}
+
+		if !opts.ListDirectories {
+			r.Next(w)
+			return
+		}
+
+		// relative links in the listing require a trailing slash
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			http.Redirect(w, r.Request, path.Base(r.URL.Path)+"/", 301)
+			return
+		}
+
+		listDirectory(w, f)
+	})
+}
+
+// listDirectory writes a simple HTML listing of a directory's entries.
+func listDirectory(w ResponseWriter, dir http.File) {
+	entries, err := dir.Readdir(-1)
+	if err != nil {
+		http.Error(w, "Internal server error.\n", 500)
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	var b strings.Builder
+	b.WriteString("<!doctype html>\n<pre>\n")
+	for _, fi := range entries {
+		name := fi.Name()
+		if fi.IsDir() {
+			name += "/"
+		}
+
+		u := url.URL{Path: name}
+		fmt.Fprintf(&b, "<a href=\"%s\">%s</a>\n", html.EscapeString(u.String()), html.EscapeString(name))
+	}
+	b.WriteString("</pre>\n")
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
+
+// Static registers a FileServer serving the files in dir for GET and HEAD
+// requests with paths under prefix, so that "/assets/app.css" is served
+// from dir's "app.css" when prefix is "/assets". Requests for files which
+// don't exist fall through to the remaining routes.
+func (m *Mux) Static(prefix, dir string) *Route {
+	return m.Add("GET|HEAD", strings.TrimRight(prefix, "/")+"/*", FileServer(http.Dir(dir)))
+}
+
+// Favicon loads the icon file at path, and serves it from memory for GET
+// and HEAD requests for "/favicon.ico", with headers allowing clients to
+// cache it for a year. The icon is served ahead of routing (see Always), so
+// these requests bypass the Use middleware and routes. An error is returned
+// if the file can't be read, in which case nothing is registered.
+func (m *Mux) Favicon(path string) error {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	ctype := mime.TypeByExtension(filepath.Ext(path))
+	if ctype == "" {
+		ctype = "image/x-icon"
+	}
+
+	modtime := time.Now()
+
+	m.Always(func(w ResponseWriter, r *Request) {
+		if r.URL.Path != "/favicon.ico" || r.Method != "GET" && r.Method != "HEAD" {
+			r.Next(w)
+			return
+		}
+
+		h := w.Header()
+		h.Set("Content-Type", ctype)
+		h.Set("Cache-Control", "public, max-age=31536000")
+		http.ServeContent(w, r.Request, "favicon.ico", modtime, bytes.NewReader(buf))
+	})
+
+	return nil
+}
+
+// ServeFile serves the named file from the operating system's file system,
+// with the same semantics as FileServer. Unlike FileServer, name is used
+// as-is, and must not be built from unsanitized user input.
+func ServeFile(w ResponseWriter, r *Request, name string) {
+	f, err := os.Open(name)
+	if err != nil {
+		r.Next(w)
+		return
+	}
+	defer f.Close()
+
+	serveContent(w, r, f)
+}
+
+// serveContent serves an open file, or calls Next if it isn't a regular
+// file.
+func serveContent(w ResponseWriter, r *Request, f interface {
+	io.ReadSeeker
+	Stat() (os.FileInfo, error)
+}) {
+	fi, err := f.Stat()
+	if err != nil || !fi.Mode().IsRegular() {
+		r.Next(w)
+		return
+	}
+
+	http.ServeContent(w, r.Request, fi.Name(), fi.ModTime(), f)
+}
+
+// PathSegments splits the value of a (typically wildcard) parameter into
+// its slash-separated segments, which makes it safe to use as a relative
+// file path. The value has already been percent-decoded as part of the
+// request's URL.Path.
+//
+// It returns nil if any segment is empty, "." or "..", including any
+// produced by decoding (like "%2e%2e"), and an empty slice if the value
+// itself is empty.
+func PathSegments(r *Request, key string) []string {
+	return splitSegments(r.Param(key))
+}
+
+// splitSegments implements PathSegments for a parameter value.
+func splitSegments(value string) []string {
+	if value == "" {
+		return []string{}
+	}
+
+	segments := strings.Split(value, "/")
+	for _, s := range segments {
+		if s == "" || s == "." || s == ".." || strings.IndexByte(s, 0) >= 0 {
+			return nil
+		}
+	}
+
+	return segments
+}
@@ -0,0 +1,230 @@
+package robo
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var pathSegmentsTests = []struct {
+	path     string
+	segments []string
+}{
+	{"/files/", []string{}},
+	{"/files/a", []string{"a"}},
+	{"/files/a/b/c", []string{"a", "b", "c"}},
+	{"/files/a%20b/%C3%A5", []string{"a b", "å"}},
+	{"/files/a/../b", nil},
+	{"/files/a/%2e%2e/b", nil},
+	{"/files/..%2fb", nil},
+	{"/files/a//b", nil},
+	{"/files/a/./b", nil},
+	{"/files/a/", nil},
+}
+
+func TestPathSegments(t *testing.T) {
+	for _, test := range pathSegmentsTests {
+		var segments []string
+
+		mux := NewMux()
+		mux.Get("/files/*", func(w ResponseWriter, r *Request) {
+			segments = PathSegments(r, "*")
+		})
+
+		serve(mux, "GET", test.path)
+
+		if (segments == nil) != (test.segments == nil) ||
+			strings.Join(segments, "|") != strings.Join(test.segments, "|") {
+			t.Errorf("PathSegments, GET %s:", test.path)
+			t.Errorf("  got  %q", segments)
+			t.Errorf("  want %q", test.segments)
+		}
+	}
+}
+
+// testFiles returns a file system with a few test files.
+func testFiles(t *testing.T) http.FileSystem {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"hello.txt":       "Hello, world!",
+		"sub/robo.html":   "<p>robo</p>",
+		"site/index.html": "<p>index</p>",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return http.Dir(dir)
+}
+
+var fileServerTests = []struct {
+	path   string
+	rng    string
+	code   int
+	body   string
+	crange string
+}{
+	{"/static/hello.txt", "", 200, "Hello, world!", ""},
+	{"/static/hello.txt", "bytes=0-4", 206, "Hello", "bytes 0-4/13"},
+	{"/static/hello.txt", "bytes=7-", 206, "world!", "bytes 7-12/13"},
+	{"/static/hello.txt", "bytes=100-200", 416, "", "bytes */13"},
+	{"/static/sub/robo.html", "", 200, "<p>robo</p>", ""},
+	{"/static/missing.txt", "", 404, "fallthrough", ""},
+	{"/static/sub", "", 404, "fallthrough", ""},
+	{"/static/sub/../hello.txt", "", 404, "fallthrough", ""},
+}
+
+func TestFileServer(t *testing.T) {
+	mux := NewMux()
+	mux.Get("/static/*", FileServer(testFiles(t)))
+	mux.NotFound(func(w ResponseWriter, r *Request) {
+		w.WriteHeader(404)
+		w.Write([]byte("fallthrough"))
+	})
+
+	for _, test := range fileServerTests {
+		hr := httptest.NewRequest("GET", test.path, nil)
+		if test.rng != "" {
+			hr.Header.Set("Range", test.rng)
+		}
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, hr)
+
+		body := w.Body.String()
+		if test.code == 416 {
+			body = ""
+		}
+
+		if w.Code != test.code || body != test.body || w.Header().Get("Content-Range") != test.crange {
+			t.Errorf("GET %s (Range %q):", test.path, test.rng)
+			t.Errorf("  got  %d %q (Content-Range %q)", w.Code, body, w.Header().Get("Content-Range"))
+			t.Errorf("  want %d %q (Content-Range %q)", test.code, test.body, test.crange)
+		}
+	}
+}
+
+func TestStatic(t *testing.T) {
+	mux := NewMux()
+	mux.Static("/assets/", string(testFiles(t).(http.Dir)))
+
+	var tests = []struct {
+		method string
+		path   string
+		code   int
+		body   string
+	}{
+		{"GET", "/assets/hello.txt", 200, "Hello, world!"},
+		{"HEAD", "/assets/hello.txt", 200, ""},
+		{"GET", "/assets/sub/robo.html", 200, "<p>robo</p>"},
+		{"GET", "/assets/missing.txt", 404, "Not found.\n\n"},
+		{"GET", "/assets/sub/../hello.txt", 404, "Not found.\n\n"},
+		{"GET", "/assets/%2e%2e/hello.txt", 404, "Not found.\n\n"},
+		{"GET", "/hello.txt", 404, "Not found.\n\n"},
+	}
+
+	for _, test := range tests {
+		w := serve(mux, test.method, test.path)
+		if w.Code != test.code || w.Body.String() != test.body {
+			t.Errorf("%s %s:", test.method, test.path)
+			t.Errorf("  got  %d %q", w.Code, w.Body.String())
+			t.Errorf("  want %d %q", test.code, test.body)
+		}
+	}
+}
+
+var directoryTests = []struct {
+	list     bool
+	path     string
+	code     int
+	body     string
+	location string
+}{
+	{false, "/static/site/", 200, "<p>index</p>", ""},
+	{false, "/static/site", 200, "<p>index</p>", ""},
+	{false, "/static/sub/", 404, "Not found.\n\n", ""},
+	{false, "/static/", 404, "Not found.\n\n", ""},
+	{false, "/static/hello.txt/", 404, "Not found.\n\n", ""},
+	{true, "/static/site/", 200, "<p>index</p>", ""},
+	{true, "/static/sub", 301, "", "/static/sub/"},
+	{true, "/static/sub/", 200, "<!doctype html>\n<pre>\n<a href=\"robo.html\">robo.html</a>\n</pre>\n", ""},
+	{true, "/static/", 200, "<!doctype html>\n<pre>\n" +
+		"<a href=\"hello.txt\">hello.txt</a>\n<a href=\"site/\">site/</a>\n<a href=\"sub/\">sub/</a>\n</pre>\n", ""},
+}
+
+func TestFileServerDirectories(t *testing.T) {
+	root := testFiles(t)
+
+	for _, test := range directoryTests {
+		mux := NewMux()
+		mux.Get("/static/*", NewFileServer(root, FileServerOptions{ListDirectories: test.list}))
+
+		w := serve(mux, "GET", test.path)
+
+		body := w.Body.String()
+		if test.code == 301 {
+			body = ""
+		}
+
+		if w.Code != test.code || body != test.body || w.Header().Get("Location") != test.location {
+			t.Errorf("GET %s (listing %v):", test.path, test.list)
+			t.Errorf("  got  %d %q (Location %q)", w.Code, body, w.Header().Get("Location"))
+			t.Errorf("  want %d %q (Location %q)", test.code, test.body, test.location)
+		}
+	}
+}
+
+func TestFavicon(t *testing.T) {
+	dir := t.TempDir()
+	icon := filepath.Join(dir, "favicon.ico")
+	if err := os.WriteFile(icon, []byte("\x00\x00\x01\x00icon"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var log []string
+
+	mux := NewMux()
+	if err := mux.Favicon(icon); err != nil {
+		t.Fatalf("Favicon: %v", err)
+	}
+	mux.Use(trace(&log, "middleware"))
+	mux.Get("*", echo("*"))
+
+	// the file is only read once
+	os.Remove(icon)
+
+	w := serve(mux, "GET", "/favicon.ico")
+	if w.Code != 200 || w.Body.String() != "\x00\x00\x01\x00icon" || len(log) != 0 {
+		t.Errorf("GET /favicon.ico: got %d %q (log %v)", w.Code, w.Body.String(), log)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "image/x-icon" && ct != "image/vnd.microsoft.icon" {
+		t.Errorf("GET /favicon.ico: Content-Type = %q, want an icon type", ct)
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "public, max-age=31536000" {
+		t.Errorf("GET /favicon.ico: Cache-Control = %q", cc)
+	}
+
+	if w := serve(mux, "GET", "/other"); w.Body.String() != "*" {
+		t.Errorf("GET /other: got %q, want %q", w.Body.String(), "*")
+	}
+
+	missing := NewMux()
+	if err := missing.Favicon(icon); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Favicon(missing file) = %v, want %v", err, os.ErrNotExist)
+	}
+	if w := serve(missing, "GET", "/favicon.ico"); w.Code != 404 {
+		t.Errorf("GET /favicon.ico without a favicon: got %d, want 404", w.Code)
+	}
+}
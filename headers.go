@@ -0,0 +1,97 @@
+package robo
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RewriteHeaders returns a middleware handler which lets fn modify the
+// response header right before it is committed, after the remaining
+// handlers have had their say. This is useful for removing headers, or
+// adding ones which must be present on every response.
+func RewriteHeaders(fn func(h http.Header)) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		hw := newHookWriter(w, func() {
+			fn(w.Header())
+		})
+
+		r.Next(hw.writer())
+
+		// the header is committed implicitly if nothing was written
+		hw.commit(200)
+	})
+}
+
+// Vary returns a middleware handler which adds the given header names to
+// the response's Vary header before calling Next. Names already listed
+// (compared case-insensitively) are not repeated.
+func Vary(headers ...string) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		addVary(w.Header(), headers...)
+		r.Next(w)
+	})
+}
+
+// addVary adds header names to the Vary header of h, skipping duplicates.
+func addVary(h http.Header, names ...string) {
+	var list []string
+	for _, v := range h.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				list = append(list, name)
+			}
+		}
+	}
+
+	n := len(list)
+
+outer:
+	for _, name := range names {
+		for _, seen := range list {
+			if seen == "*" || strings.EqualFold(seen, name) {
+				continue outer
+			}
+		}
+		list = append(list, http.CanonicalHeaderKey(name))
+	}
+
+	if len(list) > n {
+		h.Set("Vary", strings.Join(list, ", "))
+	}
+}
+
+// NotModified sets the response's Last-Modified header to modtime and
+// checks it against the request's If-Modified-Since header, the same way
+// http.ServeContent does. If the client's copy is still fresh it sends a
+// 304 response and returns true, in which case the handler should skip
+// generating a body. A zero modtime disables the check.
+func NotModified(w ResponseWriter, r *Request, modtime time.Time) bool {
+	if modtime.IsZero() || modtime.Equal(time.Unix(0, 0)) {
+		return false
+	}
+
+	w.Header().Set("Last-Modified", modtime.UTC().Format(http.TimeFormat))
+
+	// If-None-Match takes precedence, and is left to the handler
+	if r.Method != "GET" && r.Method != "HEAD" || r.Header.Get("If-None-Match") != "" {
+		return false
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+
+	// the header has a resolution of one second
+	if modtime.Truncate(time.Second).After(since) {
+		return false
+	}
+
+	h := w.Header()
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	w.WriteHeader(304)
+
+	return true
+}
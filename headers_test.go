@@ -0,0 +1,118 @@
+package robo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRewriteHeaders(t *testing.T) {
+	var calls int
+
+	mux := NewMux()
+	mux.Use(RewriteHeaders(func(h http.Header) {
+		calls++
+		h.Del("Server")
+		h.Set("X-Frame-Options", "DENY")
+	}))
+	mux.Get("/write", func(w ResponseWriter, r *Request) {
+		w.Header().Set("Server", "robo")
+		w.Write([]byte("hello"))
+		w.Write([]byte(", world"))
+	})
+	mux.Get("/status", func(w ResponseWriter, r *Request) {
+		w.Header().Set("Server", "robo")
+		w.WriteHeader(204)
+	})
+	mux.Get("/empty", func(w ResponseWriter, r *Request) {
+		w.Header().Set("Server", "robo")
+	})
+
+	for _, path := range []string{"/write", "/status", "/empty"} {
+		calls = 0
+
+		w := serve(mux, "GET", path)
+		h := w.Header()
+
+		if calls != 1 || h.Get("Server") != "" || h.Get("X-Frame-Options") != "DENY" {
+			t.Errorf("GET %s:", path)
+			t.Errorf("  got  %d calls, Server %q, X-Frame-Options %q", calls, h.Get("Server"), h.Get("X-Frame-Options"))
+			t.Errorf("  want 1 call, Server \"\", X-Frame-Options \"DENY\"")
+		}
+	}
+}
+
+var varyTests = []struct {
+	existing []string
+	names    []string
+	vary     string
+}{
+	{nil, []string{"Accept-Encoding"}, "Accept-Encoding"},
+	{nil, []string{"accept-encoding", "Accept-Encoding", "Origin"}, "Accept-Encoding, Origin"},
+	{[]string{"Origin"}, []string{"origin", "Accept"}, "Origin, Accept"},
+	{[]string{"Origin, Accept"}, []string{"accept"}, "Origin, Accept"},
+	{[]string{"*"}, []string{"Accept"}, "*"},
+}
+
+func TestVary(t *testing.T) {
+	for _, test := range varyTests {
+		mux := NewMux()
+		mux.Use(func(w ResponseWriter, r *Request) {
+			for _, v := range test.existing {
+				w.Header().Add("Vary", v)
+			}
+			r.Next(w)
+		}, Vary(test.names...), Vary(test.names...))
+		mux.Get("/", echo("/"))
+
+		w := serve(mux, "GET", "/")
+		if vary := strings.Join(w.Header().Values("Vary"), ", "); vary != test.vary {
+			t.Errorf("Vary(%q) with existing %q:", test.names, test.existing)
+			t.Errorf("  got  %q", vary)
+			t.Errorf("  want %q", test.vary)
+		}
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	modtime := time.Date(2020, 1, 2, 3, 4, 5, 600, time.UTC)
+
+	var fresh bool
+	mux := NewMux()
+	mux.Get("/", func(w ResponseWriter, r *Request) {
+		if fresh = NotModified(w, r, modtime); !fresh {
+			w.Write([]byte("body"))
+		}
+	})
+
+	var tests = []struct {
+		since string
+		fresh bool
+		code  int
+	}{
+		{"", false, 200},
+		{"Thu, 02 Jan 2020 03:04:05 GMT", true, 304},
+		{"Thu, 02 Jan 2020 04:00:00 GMT", true, 304},
+		{"Thu, 02 Jan 2020 03:04:04 GMT", false, 200},
+		{"garbage", false, 200},
+	}
+
+	for _, test := range tests {
+		hr := httptest.NewRequest("GET", "/", nil)
+		if test.since != "" {
+			hr.Header.Set("If-Modified-Since", test.since)
+		}
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, hr)
+
+		lm := w.Header().Get("Last-Modified")
+		if fresh != test.fresh || w.Code != test.code || lm != "Thu, 02 Jan 2020 03:04:05 GMT" {
+			t.Errorf("If-Modified-Since %q:", test.since)
+			t.Errorf("  got  %v, status %d, Last-Modified %q", fresh, w.Code, lm)
+			t.Errorf("  want %v, status %d, Last-Modified %q", test.fresh, test.code, "Thu, 02 Jan 2020 03:04:05 GMT")
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package robo
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// healthTimeout is how long Health waits for each check to complete.
+var healthTimeout = 5 * time.Second
+
+var errHealthTimeout = errors.New("timed out")
+
+// Health returns a handler for health or readiness endpoints. It runs all
+// named checks concurrently, and responds with a JSON object like
+//
+//	{"status": "ok", "checks": {"db": "ok"}}
+//
+// and status 200 if they all succeed, or status 503 and the error message
+// of each failed check (with "status" being "error") otherwise. Checks
+// which take longer than five seconds are considered failed.
+func Health(checks map[string]func() error) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		type result struct {
+			name string
+			err  error
+		}
+
+		results := make(chan result, len(checks))
+		timeout := time.After(healthTimeout)
+
+		for name, check := range checks {
+			go func(name string, check func() error) {
+				results <- result{name, check()}
+			}(name, check)
+		}
+
+		status := "ok"
+		report := make(map[string]string, len(checks))
+
+		for name := range checks {
+			report[name] = errHealthTimeout.Error()
+		}
+
+	collect:
+		for range checks {
+			select {
+			case res := <-results:
+				if res.err != nil {
+					report[res.name] = res.err.Error()
+				} else {
+					report[res.name] = "ok"
+				}
+			case <-timeout:
+				break collect
+			}
+		}
+
+		code := 200
+		for _, v := range report {
+			if v != "ok" {
+				status, code = "error", 503
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(code)
+
+		json.NewEncoder(w).Encode(struct {
+			Status string            `json:"status"`
+			Checks map[string]string `json:"checks"`
+		}{status, report})
+	})
+}
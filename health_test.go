@@ -0,0 +1,60 @@
+package robo
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	defer func(d time.Duration) { healthTimeout = d }(healthTimeout)
+	healthTimeout = 20 * time.Millisecond
+
+	ok := func() error { return nil }
+	fail := func() error { return errors.New("connection refused") }
+	slow := func() error { time.Sleep(time.Second); return nil }
+
+	var tests = []struct {
+		checks map[string]func() error
+		code   int
+		status string
+		report map[string]string
+	}{
+		{map[string]func() error{"db": ok, "cache": ok}, 200, "ok",
+			map[string]string{"db": "ok", "cache": "ok"}},
+		{map[string]func() error{"db": ok, "cache": fail}, 503, "error",
+			map[string]string{"db": "ok", "cache": "connection refused"}},
+		{map[string]func() error{"db": slow}, 503, "error",
+			map[string]string{"db": "timed out"}},
+		{nil, 200, "ok", map[string]string{}},
+	}
+
+	for _, test := range tests {
+		mux := NewMux()
+		mux.Get("/healthz", Health(test.checks))
+
+		w := serve(mux, "GET", "/healthz")
+
+		var body struct {
+			Status string
+			Checks map[string]string
+		}
+		err := json.Unmarshal(w.Body.Bytes(), &body)
+
+		if err != nil || w.Code != test.code || body.Status != test.status || len(body.Checks) != len(test.report) {
+			goto fail
+		}
+		for name, v := range test.report {
+			if body.Checks[name] != v {
+				goto fail
+			}
+		}
+		continue
+
+	fail:
+		t.Errorf("Health:")
+		t.Errorf("  got  %d %s", w.Code, w.Body.String())
+		t.Errorf("  want %d %s %v", test.code, test.status, test.report)
+	}
+}
@@ -0,0 +1,29 @@
+package robo
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"strconv"
+)
+
+// HTML executes the named template into a buffer, and then writes it to w
+// with the given status code and an HTML Content-Type. If the template
+// fails to execute, a plain 500 response is sent in its place and the
+// error is returned, so clients never see a partially rendered page.
+func HTML(w ResponseWriter, status int, t *template.Template, name string, data interface{}) error {
+	var buf bytes.Buffer
+
+	if err := t.ExecuteTemplate(&buf, name, data); err != nil {
+		http.Error(w, "Internal server error.\n", 500)
+		return err
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	h.Set("Content-Length", strconv.Itoa(buf.Len()))
+
+	w.WriteHeader(status)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,40 @@
+package robo
+
+import (
+	"html/template"
+	"net/http/httptest"
+	"testing"
+)
+
+var htmlTemplates = template.Must(template.New("").Parse(`
+{{define "hello"}}<p>Hello, {{.}}.</p>{{end}}
+{{define "broken"}}<p>{{.Missing}}</p>{{end}}
+`))
+
+var htmlTests = []struct {
+	name   string
+	status int
+	err    bool
+	code   int
+	ctype  string
+	body   string
+}{
+	{"hello", 201, false, 201, "text/html; charset=utf-8", "<p>Hello, &lt;robo&gt;.</p>"},
+	{"broken", 200, true, 500, "text/plain; charset=utf-8", "Internal server error.\n\n"},
+	{"missing", 200, true, 500, "text/plain; charset=utf-8", "Internal server error.\n\n"},
+}
+
+func TestHTML(t *testing.T) {
+	for _, test := range htmlTests {
+		w := httptest.NewRecorder()
+
+		err := HTML(w, test.status, htmlTemplates, test.name, "<robo>")
+		ctype := w.Header().Get("Content-Type")
+
+		if (err != nil) != test.err || w.Code != test.code || ctype != test.ctype || w.Body.String() != test.body {
+			t.Errorf("HTML(%d, %q):", test.status, test.name)
+			t.Errorf("  got  %v, %d %q %q", err, w.Code, ctype, w.Body.String())
+			t.Errorf("  want err %v, %d %q %q", test.err, test.code, test.ctype, test.body)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package robo
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTPSOptions configures the RequireHTTPS middleware.
+type HTTPSOptions struct {
+	// MaxAge is the max-age directive of the Strict-Transport-Security
+	// header sent with secure responses. No header is sent if it is zero.
+	MaxAge time.Duration
+
+	// IncludeSubdomains and Preload add the corresponding directives to
+	// the Strict-Transport-Security header.
+	IncludeSubdomains bool
+	Preload           bool
+
+	// TrustedProxies lists the networks (in CIDR notation, or as plain
+	// IP addresses) allowed to declare a request secure through the
+	// X-Forwarded-Proto header.
+	TrustedProxies []string
+}
+
+// RequireHTTPS returns a middleware handler which redirects insecure
+// requests to their https equivalent, and adds a Strict-Transport-Security
+// header to secure ones before calling Next.
+//
+// GET and HEAD requests are redirected with a 301, other methods with a
+// 308 to make sure clients preserve the method and body.
+func RequireHTTPS(opts HTTPSOptions) Handler {
+	trusted := parseNetworks(opts.TrustedProxies)
+
+	var hsts string
+	if opts.MaxAge > 0 {
+		hsts = "max-age=" + strconv.FormatInt(int64(opts.MaxAge/time.Second), 10)
+		if opts.IncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if opts.Preload {
+			hsts += "; preload"
+		}
+	}
+
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		if requestScheme(r.Request, trusted) != "https" {
+			code := 308
+			if r.Method == "GET" || r.Method == "HEAD" {
+				code = 301
+			}
+
+			http.Redirect(w, r.Request, "https://"+r.Host+r.URL.RequestURI(), code)
+			return
+		}
+
+		if hsts != "" {
+			w.Header().Set("Strict-Transport-Security", hsts)
+		}
+
+		r.Next(w)
+	})
+}
+
+// CanonicalHost returns a middleware handler which redirects requests for
+// any host other than domain to the same path and query on domain, using
+// the given redirect status (typically 301 or 308). Hosts are compared
+// case-insensitively and without their port, so "Example.com:8080" is the
+// same host as "example.com". The request's scheme is preserved.
+//
+// Requests from trustedProxies (networks in CIDR notation, or plain IP
+// addresses, as for HTTPSOptions.TrustedProxies) may declare their scheme
+// through the X-Forwarded-Proto header, so that requests arriving through
+// a TLS-terminating proxy are redirected to https.
+func CanonicalHost(domain string, status int, trustedProxies ...string) Handler {
+	domain = strings.ToLower(domain)
+	trusted := parseNetworks(trustedProxies)
+
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		if requestHost(r.Request) == domain {
+			r.Next(w)
+			return
+		}
+
+		url := requestScheme(r.Request, trusted) + "://" + domain + r.URL.RequestURI()
+		http.Redirect(w, r.Request, url, status)
+	})
+}
@@ -0,0 +1,103 @@
+package robo
+
+import (
+	"crypto/tls"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var requireHTTPSTests = []struct {
+	method   string
+	tls      bool
+	remote   string
+	proto    string
+	code     int
+	location string
+	hsts     string
+}{
+	{"GET", false, "192.0.2.1:1234", "", 301, "https://example.com/foo?a=b", ""},
+	{"POST", false, "192.0.2.1:1234", "", 308, "https://example.com/foo?a=b", ""},
+	{"GET", true, "192.0.2.1:1234", "", 200, "", "max-age=3600; includeSubDomains"},
+	{"GET", false, "10.0.0.1:1234", "https", 200, "", "max-age=3600; includeSubDomains"},
+	{"GET", false, "192.0.2.1:1234", "https", 301, "https://example.com/foo?a=b", ""},
+}
+
+func TestRequireHTTPS(t *testing.T) {
+	for _, test := range requireHTTPSTests {
+		mux := NewMux()
+		mux.Any("*", RequireHTTPS(HTTPSOptions{
+			MaxAge:            time.Hour,
+			IncludeSubdomains: true,
+			TrustedProxies:    []string{"10.0.0.0/8"},
+		}))
+		mux.Any("/foo", echo("/foo"))
+
+		hr := httptest.NewRequest(test.method, "http://example.com/foo?a=b", nil)
+		hr.RemoteAddr = test.remote
+		if test.tls {
+			hr.TLS = &tls.ConnectionState{}
+		}
+		if test.proto != "" {
+			hr.Header.Set("X-Forwarded-Proto", test.proto)
+		}
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, hr)
+
+		location := w.Header().Get("Location")
+		hsts := w.Header().Get("Strict-Transport-Security")
+
+		if w.Code != test.code || location != test.location || hsts != test.hsts {
+			t.Errorf("RequireHTTPS, %s (tls %v, from %s, proto %q):", test.method, test.tls, test.remote, test.proto)
+			t.Errorf("  got  %d %q %q", w.Code, location, hsts)
+			t.Errorf("  want %d %q %q", test.code, test.location, test.hsts)
+		}
+	}
+}
+
+var canonicalHostTests = []struct {
+	target   string
+	tls      bool
+	remote   string
+	proto    string
+	code     int
+	location string
+}{
+	{"http://example.com/foo?a=b", false, "", "", 200, ""},
+	{"http://EXAMPLE.com:8080/foo", false, "", "", 200, ""},
+	{"http://www.example.com/foo?a=b", false, "", "", 301, "http://example.com/foo?a=b"},
+	{"http://www.example.com:8443/foo", true, "", "", 301, "https://example.com/foo"},
+
+	// forwarded by a TLS-terminating proxy
+	{"http://www.example.com/foo", false, "10.0.0.1:1234", "https", 301, "https://example.com/foo"},
+	{"http://www.example.com/foo", false, "192.0.2.1:1234", "https", 301, "http://example.com/foo"},
+}
+
+func TestCanonicalHost(t *testing.T) {
+	mux := NewMux()
+	mux.Use(CanonicalHost("example.com", 301, "10.0.0.0/8"))
+	mux.Any("/foo", echo("/foo"))
+
+	for _, test := range canonicalHostTests {
+		hr := httptest.NewRequest("GET", test.target, nil)
+		if test.tls {
+			hr.TLS = &tls.ConnectionState{}
+		}
+		if test.remote != "" {
+			hr.RemoteAddr = test.remote
+		}
+		if test.proto != "" {
+			hr.Header.Set("X-Forwarded-Proto", test.proto)
+		}
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, hr)
+
+		if w.Code != test.code || w.Header().Get("Location") != test.location {
+			t.Errorf("GET %s (tls %v, from %q, proto %q):", test.target, test.tls, test.remote, test.proto)
+			t.Errorf("  got  %d %q", w.Code, w.Header().Get("Location"))
+			t.Errorf("  want %d %q", test.code, test.location)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package robo
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// IdempotencyStore is the interface implemented by backends storing the
+// responses recorded by the Idempotency middleware. Implementations must
+// be safe for concurrent use.
+type IdempotencyStore interface {
+	// Get returns the response stored for key, if any.
+	Get(key string) (*StoredResponse, bool)
+
+	// Put stores a response for key.
+	Put(key string, resp *StoredResponse)
+}
+
+// The StoredResponse type holds a response recorded by Idempotency.
+type StoredResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Idempotency returns a middleware handler which makes requests with an
+// unsafe method (like POST) and an Idempotency-Key header safe to retry.
+// The first response for a key is recorded in store, and later requests
+// with the same key receive a replay of it instead of being passed on.
+// Responses with a 5xx status aren't recorded, so that failed requests can
+// be retried for real.
+//
+// Keys are scoped to the request's method and path, so the same key sent
+// to another endpoint isn't answered with an unrelated response; the keys
+// passed to store combine all three. A key reused for a different request
+// to the same endpoint still gets the original response, so clients should
+// derive keys from (or stores should also check) a fingerprint of the
+// request, like a hash of its body.
+//
+// Requests with the same key which arrive while the first one is still
+// being served are passed on as well; stores which need to prevent that
+// must implement their own locking.
+func Idempotency(store IdempotencyStore) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" || isSafeMethod(r.Method) {
+			r.Next(w)
+			return
+		}
+		key = r.Method + " " + r.URL.EscapedPath() + " " + key
+
+		if resp, ok := store.Get(key); ok {
+			h := w.Header()
+			for k, v := range resp.Header {
+				h[k] = v
+			}
+			w.WriteHeader(resp.Status)
+			w.Write(resp.Body)
+			return
+		}
+
+		rw := &recordWriter{}
+		rw.hookWriter = newHookWriter(w, func() {
+			rw.header = w.Header().Clone()
+		})
+
+		r.Next(rw)
+		rw.commit(200)
+
+		if rw.status < 500 {
+			store.Put(key, &StoredResponse{rw.status, rw.header, rw.body.Bytes()})
+		}
+	})
+}
+
+// isSafeMethod reports whether a method is defined as safe (read-only).
+func isSafeMethod(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "TRACE":
+		return true
+	}
+	return false
+}
+
+// recordWriter is a hookWriter which also keeps a copy of the response. It
+// hides the optional interfaces of the wrapped writer, like http.Hijacker,
+// since a hijacked or streamed response couldn't be stored for replay.
+type recordWriter struct {
+	*hookWriter
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (w *recordWriter) Write(buf []byte) (int, error) {
+	n, err := w.hookWriter.Write(buf)
+	w.body.Write(buf[:n])
+	return n, err
+}
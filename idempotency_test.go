@@ -0,0 +1,85 @@
+package robo
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// memoryStore is an in-memory IdempotencyStore.
+type memoryStore struct {
+	mu sync.Mutex
+	m  map[string]*StoredResponse
+}
+
+func (s *memoryStore) Get(key string) (*StoredResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp, ok := s.m[key]
+	return resp, ok
+}
+
+func (s *memoryStore) Put(key string, resp *StoredResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m[key] = resp
+}
+
+func TestIdempotency(t *testing.T) {
+	var calls int
+
+	mux := NewMux()
+	mux.Use(Idempotency(&memoryStore{m: make(map[string]*StoredResponse)}))
+	mux.Add("GET|POST", "/orders", func(w ResponseWriter, r *Request) {
+		calls++
+		w.Header().Set("X-Order", fmt.Sprint(calls))
+		w.WriteHeader(201)
+		fmt.Fprintf(w, "order %d", calls)
+	})
+	mux.Post("/carts", func(w ResponseWriter, r *Request) {
+		calls++
+		fmt.Fprintf(w, "cart %d", calls)
+	})
+	mux.Post("/fail", func(w ResponseWriter, r *Request) {
+		calls++
+		w.WriteHeader(503)
+	})
+
+	var tests = []struct {
+		method string
+		path   string
+		key    string
+		calls  int
+		code   int
+		body   string
+	}{
+		{"POST", "/orders", "a", 1, 201, "order 1"},
+		{"POST", "/orders", "a", 1, 201, "order 1"},
+		{"POST", "/orders", "b", 2, 201, "order 2"},
+		{"POST", "/orders", "", 3, 201, "order 3"},
+		{"GET", "/orders", "a", 4, 201, "order 4"},
+		{"POST", "/orders", "a", 4, 201, "order 1"},
+		{"POST", "/fail", "c", 5, 503, ""},
+		{"POST", "/fail", "c", 6, 503, ""},
+		{"POST", "/carts", "a", 7, 200, "cart 7"},
+		{"POST", "/carts", "a", 7, 200, "cart 7"},
+	}
+
+	for _, test := range tests {
+		hr := httptest.NewRequest(test.method, test.path, nil)
+		if test.key != "" {
+			hr.Header.Set("Idempotency-Key", test.key)
+		}
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, hr)
+
+		if calls != test.calls || w.Code != test.code || w.Body.String() != test.body ||
+			test.code == 201 && w.Header().Get("X-Order") != test.body[len("order "):] {
+			t.Errorf("%s %s (key %q):", test.method, test.path, test.key)
+			t.Errorf("  got  %d calls, %d %q (X-Order %q)", calls, w.Code, w.Body.String(), w.Header().Get("X-Order"))
+			t.Errorf("  want %d calls, %d %q", test.calls, test.code, test.body)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package robo
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+var errStreamClosed = errors.New("robo: JSON stream already closed")
+
+// jsonStreamFlushInterval is the number of elements a JSONStreamEncoder
+// writes between flushes.
+const jsonStreamFlushInterval = 64
+
+// JSONStreamEncoder writes a JSON array to a response incrementally, one
+// element at a time, so large result sets don't have to be buffered in
+// memory.
+type JSONStreamEncoder struct {
+	w   ResponseWriter
+	n   int
+	err error
+}
+
+// JSONStream sets the response's Content-Type and returns an encoder for
+// streaming a JSON array to w. The caller must call Close once all
+// elements have been encoded.
+func JSONStream(w ResponseWriter) *JSONStreamEncoder {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	return &JSONStreamEncoder{w: w}
+}
+
+// Encode writes v as the next element of the array. Values which can't be
+// marshalled are reported without being written, while write errors are
+// persistent.
+func (e *JSONStreamEncoder) Encode(v interface{}) error {
+	if e.err != nil {
+		return e.err
+	}
+
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	if e.n == 0 {
+		buf = append([]byte{'['}, buf...)
+	} else {
+		buf = append([]byte{','}, buf...)
+	}
+
+	if _, e.err = e.w.Write(buf); e.err != nil {
+		return e.err
+	}
+
+	if e.n++; e.n%jsonStreamFlushInterval == 0 {
+		e.flush()
+	}
+
+	return nil
+}
+
+// Close terminates the array and flushes the response.
+func (e *JSONStreamEncoder) Close() error {
+	if e.err != nil {
+		return e.err
+	}
+
+	tail := "]"
+	if e.n == 0 {
+		tail = "[]"
+	}
+
+	if _, e.err = e.w.Write([]byte(tail)); e.err != nil {
+		return e.err
+	}
+
+	e.flush()
+	e.err = errStreamClosed
+	return nil
+}
+
+func (e *JSONStreamEncoder) flush() {
+	if f, ok := e.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Created responds with a 201, with the Location header set to location
+// and body (unless nil) encoded as JSON. If body can't be encoded, a plain
+// 500 response is sent instead and the error is returned.
+func Created(w ResponseWriter, location string, body interface{}) error {
+	var buf []byte
+
+	if body != nil {
+		var err error
+		if buf, err = json.Marshal(body); err != nil {
+			http.Error(w, "Internal server error.\n", 500)
+			return err
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	}
+
+	w.Header().Set("Location", location)
+	w.WriteHeader(201)
+
+	if buf != nil {
+		_, err := w.Write(buf)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package robo
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+var jsonStreamTests = []struct {
+	values []interface{}
+	output string
+}{
+	{nil, `[]`},
+	{[]interface{}{1}, `[1]`},
+	{[]interface{}{"a", 2, map[string]bool{"c": true}, nil}, `["a",2,{"c":true},null]`},
+}
+
+func TestJSONStream(t *testing.T) {
+	for _, test := range jsonStreamTests {
+		w := httptest.NewRecorder()
+
+		enc := JSONStream(w)
+		for _, v := range test.values {
+			if err := enc.Encode(v); err != nil {
+				t.Fatalf("Encode(%v): %v", v, err)
+			}
+		}
+		if err := enc.Close(); err != nil {
+			t.Fatalf("Close(): %v", err)
+		}
+
+		var decoded []interface{}
+		body := w.Body.String()
+
+		if body != test.output || json.Unmarshal([]byte(body), &decoded) != nil ||
+			w.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+			t.Errorf("JSONStream(%v):", test.values)
+			t.Errorf("  got  %s (%s)", body, w.Header().Get("Content-Type"))
+			t.Errorf("  want %s", test.output)
+		}
+	}
+}
+
+func TestJSONStreamFlush(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	enc := JSONStream(w)
+	for i := 0; i < jsonStreamFlushInterval; i++ {
+		enc.Encode(i)
+	}
+
+	if !w.Flushed {
+		t.Errorf("expected a flush after %d elements", jsonStreamFlushInterval)
+	}
+}
+
+var createdTests = []struct {
+	body  interface{}
+	err   bool
+	code  int
+	ctype string
+	out   string
+}{
+	{map[string]int{"id": 5}, false, 201, "application/json; charset=utf-8", `{"id":5}`},
+	{nil, false, 201, "", ""},
+	{make(chan int), true, 500, "text/plain; charset=utf-8", "Internal server error.\n\n"},
+}
+
+func TestCreated(t *testing.T) {
+	for _, test := range createdTests {
+		w := httptest.NewRecorder()
+
+		err := Created(w, "/users/5", test.body)
+		location := w.Header().Get("Location")
+		ctype := w.Header().Get("Content-Type")
+
+		wantLocation := "/users/5"
+		if test.err {
+			wantLocation = ""
+		}
+
+		if (err != nil) != test.err || w.Code != test.code || location != wantLocation ||
+			ctype != test.ctype || w.Body.String() != test.out {
+			t.Errorf("Created(%q, %v):", "/users/5", test.body)
+			t.Errorf("  got  %v, %d %q %q %q", err, w.Code, location, ctype, w.Body.String())
+			t.Errorf("  want err %v, %d %q %q %q", test.err, test.code, wantLocation, test.ctype, test.out)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package robo
+
+import (
+	"io"
+	"net/http"
+)
+
+// Concurrency returns a middleware handler which limits the number of
+// requests concurrently being served by the remaining handlers to max.
+// Requests beyond the limit wait for a slot to become available, unless
+// failFast is set, in which case they are rejected with a 503 right away.
+// Waiting requests are also rejected if their context is cancelled.
+func Concurrency(max int, failFast bool) Handler {
+	if max <= 0 {
+		panic("robo: concurrency limit must be positive")
+	}
+
+	sem := make(chan struct{}, max)
+
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		if failFast {
+			select {
+			case sem <- struct{}{}:
+			default:
+				http.Error(w, "Service unavailable.\n", 503)
+				return
+			}
+		} else {
+			select {
+			case sem <- struct{}{}:
+			case <-r.Context().Done():
+				http.Error(w, "Service unavailable.\n", 503)
+				return
+			}
+		}
+
+		defer func() { <-sem }()
+		r.Next(w)
+	})
+}
+
+// MaxBytes returns a middleware handler which limits the size of request
+// bodies to n bytes. Reading the body of a request fails with an
+// *http.MaxBytesError once the limit is exceeded, or right away if the
+// request declares a larger Content-Length. If an earlier MaxBytes has
+// already limited the body, the lower of the two limits applies.
+//
+// A limit set with Route.MaxBody replaces any limit set by MaxBytes,
+// whether MaxBytes runs as middleware of the route's Mux, of a Mux it is
+// mounted in, or with Always, which makes it possible to use MaxBytes as
+// a global default.
+func MaxBytes(n int64) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		switch b, ok := r.Body.(*bodyLimit); {
+		case ok:
+			if !b.fixed && n < b.n {
+				b.n = n
+			}
+		case r.Body != nil && r.Body != http.NoBody:
+			r.Body = &bodyLimit{rc: r.Body, n: n, length: r.ContentLength}
+		}
+		r.Next(w)
+	})
+}
+
+// MaxURLLength returns a handler which rejects requests whose request URI
+// (the target as sent by the client, including the querystring) is longer
+// than n bytes with a 414, and calls Next for other requests. Register it
+// with Mux.Always to have it run before routing.
+func MaxURLLength(n int) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		uri := r.RequestURI
+		if uri == "" {
+			uri = r.URL.RequestURI()
+		}
+
+		if len(uri) > n {
+			http.Error(w, "Request URI too long.\n", 414)
+			return
+		}
+
+		r.Next(w)
+	})
+}
+
+// limitBody limits the size of the request's body to n bytes for good,
+// replacing any limit set by MaxBytes, before calling Next. Requests
+// declaring a larger Content-Length are rejected with a 413 right away.
+func limitBody(w ResponseWriter, r *Request, n int64) {
+	if r.ContentLength > n {
+		http.Error(w, "Request entity too large.\n", 413)
+		return
+	}
+
+	switch b, ok := r.Body.(*bodyLimit); {
+	case ok:
+		b.n, b.fixed = n, true
+	case r.Body != nil && r.Body != http.NoBody:
+		r.Body = &bodyLimit{rc: r.Body, n: n, length: r.ContentLength, fixed: true}
+	}
+
+	r.Next(w)
+}
+
+// A bodyLimit limits the number of bytes read from a request body, like
+// http.MaxBytesReader, except that the limit can be changed until the body
+// is read. Once fixed is set, the limit is final.
+type bodyLimit struct {
+	rc     io.ReadCloser
+	n      int64
+	read   int64
+	length int64
+	fixed  bool
+}
+
+func (b *bodyLimit) Read(p []byte) (int, error) {
+	if b.read > b.n || b.length > b.n {
+		return 0, &http.MaxBytesError{Limit: b.n}
+	}
+
+	// read one byte beyond the limit, to tell a body which is exactly n
+	// bytes long apart from a longer one
+	if max := b.n - b.read + 1; int64(len(p)) > max {
+		p = p[:max]
+	}
+
+	k, err := b.rc.Read(p)
+	if b.read += int64(k); b.read > b.n {
+		return k - int(b.read-b.n), &http.MaxBytesError{Limit: b.n}
+	}
+	return k, err
+}
+
+func (b *bodyLimit) Close() error {
+	return b.rc.Close()
+}
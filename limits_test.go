@@ -0,0 +1,213 @@
+package robo
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// blockingMux returns a Mux whose handler signals on entered and then
+// blocks until release is closed.
+func blockingMux(limit Handler, entered chan<- bool, release <-chan bool) *Mux {
+	mux := NewMux()
+	mux.Use(limit)
+	mux.Get("/", func(w ResponseWriter, r *Request) {
+		entered <- true
+		<-release
+	})
+	return mux
+}
+
+func TestConcurrencyFailFast(t *testing.T) {
+	const max = 3
+
+	entered, release := make(chan bool), make(chan bool)
+	mux := blockingMux(Concurrency(max, true), entered, release)
+
+	var wg sync.WaitGroup
+	codes := make(chan int, max)
+
+	for i := 0; i < max; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- serve(mux, "GET", "/").Code
+		}()
+		<-entered
+	}
+
+	if w := serve(mux, "GET", "/"); w.Code != 503 {
+		t.Errorf("request %d: got %d, want 503", max+1, w.Code)
+	}
+
+	close(release)
+	wg.Wait()
+	close(codes)
+
+	for code := range codes {
+		if code != 200 {
+			t.Errorf("request within limit: got %d, want 200", code)
+		}
+	}
+}
+
+func TestConcurrencyBlocking(t *testing.T) {
+	entered, release := make(chan bool), make(chan bool)
+	mux := blockingMux(Concurrency(1, false), entered, release)
+
+	done := make(chan int, 2)
+	go func() { done <- serve(mux, "GET", "/").Code }()
+	<-entered
+
+	go func() { done <- serve(mux, "GET", "/").Code }()
+
+	select {
+	case <-entered:
+		t.Fatalf("second request entered while the first was in flight")
+	case code := <-done:
+		t.Fatalf("second request finished early with %d", code)
+	default:
+	}
+
+	release <- true
+	<-entered
+	close(release)
+
+	for i := 0; i < 2; i++ {
+		if code := <-done; code != 200 {
+			t.Errorf("got %d, want 200", code)
+		}
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	upload := func(w ResponseWriter, r *Request) {
+		buf, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "read failed", 413)
+			return
+		}
+		fmt.Fprintf(w, "%d bytes", len(buf))
+	}
+
+	mux := NewMux()
+	mux.Use(MaxBytes(8))
+	mux.Post("/upload", upload).MaxBody(32)
+	mux.Post("/form", upload)
+
+	var tests = []struct {
+		path    string
+		size    int
+		chunked bool
+		code    int
+	}{
+		{"/form", 8, false, 200},
+		{"/form", 9, false, 413},
+		{"/form", 9, true, 413},
+		{"/upload", 32, false, 200},
+		{"/upload", 32, true, 200},
+		{"/upload", 33, false, 413},
+		{"/upload", 33, true, 413},
+	}
+
+	for _, test := range tests {
+		hr := httptest.NewRequest("POST", test.path, strings.NewReader(strings.Repeat("x", test.size)))
+		if test.chunked {
+			hr.ContentLength = -1
+		}
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, hr)
+
+		if w.Code != test.code {
+			t.Errorf("POST %s (%d bytes, chunked %v):", test.path, test.size, test.chunked)
+			t.Errorf("  got  %d %q", w.Code, w.Body.String())
+			t.Errorf("  want %d", test.code)
+		}
+	}
+}
+
+func TestMaxBytesRouteOverride(t *testing.T) {
+	upload := func(w ResponseWriter, r *Request) {
+		buf, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "read failed", 413)
+			return
+		}
+		fmt.Fprintf(w, "%d bytes", len(buf))
+	}
+
+	child := NewMux()
+	child.Post("/upload", upload).MaxBody(1000)
+	child.Post("/form", upload)
+
+	parent := NewMux()
+	parent.Use(MaxBytes(10))
+	parent.Mount("/child", child)
+
+	always := NewMux()
+	always.Always(MaxBytes(10))
+	always.Post("/upload", upload).MaxBody(1000)
+	always.Post("/form", upload)
+
+	var tests = []struct {
+		mux  *Mux
+		path string
+		code int
+	}{
+		{parent, "/child/upload", 200},
+		{parent, "/child/form", 413},
+		{always, "/upload", 200},
+		{always, "/form", 413},
+	}
+
+	for _, test := range tests {
+		for _, chunked := range []bool{false, true} {
+			hr := httptest.NewRequest("POST", test.path, strings.NewReader(strings.Repeat("x", 100)))
+			if chunked {
+				hr.ContentLength = -1
+			}
+
+			w := httptest.NewRecorder()
+			test.mux.ServeHTTP(w, hr)
+
+			if w.Code != test.code {
+				t.Errorf("POST %s (chunked %v): got %d %q, want %d", test.path, chunked, w.Code, w.Body.String(), test.code)
+			}
+		}
+	}
+}
+
+func TestMaxURLLength(t *testing.T) {
+	var log []string
+
+	mux := NewMux()
+	mux.Always(MaxURLLength(16))
+	mux.Use(trace(&log, "use"))
+	mux.Get("/*", echo("/*"))
+
+	var tests = []struct {
+		target string
+		code   int
+		log    string
+	}{
+		{"/", 200, "use"},
+		{"/123456789012345", 200, "use"},
+		{"/1234567890123456", 414, ""},
+		{"/a?q=12345678901", 200, "use"},
+		{"/a?q=123456789012", 414, ""},
+	}
+
+	for _, test := range tests {
+		log = nil
+		if w := serve(mux, "GET", test.target); w.Code != test.code || strings.Join(log, " ") != test.log {
+			t.Errorf("GET %s (%d bytes):", test.target, len(test.target))
+			t.Errorf("  got  %d [%s]", w.Code, strings.Join(log, " "))
+			t.Errorf("  want %d [%s]", test.code, test.log)
+		}
+	}
+}
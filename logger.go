@@ -0,0 +1,40 @@
+package robo
+
+import (
+	"log/slog"
+)
+
+// LoggerKey is the data store key under which WithLogger stores a
+// request's logger.
+const LoggerKey = "robo.logger"
+
+// WithLogger returns a middleware handler which stores a logger for the
+// request in its data store under LoggerKey, derived from l with the
+// request's method and path attached, as well as its ID if it has an
+// X-Request-Id header. Handlers further down the chain retrieve it with
+// LoggerFromRequest. A nil l means slog.Default().
+func WithLogger(l *slog.Logger) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		base := l
+		if base == nil {
+			base = slog.Default()
+		}
+
+		attrs := []interface{}{"method", r.Method, "path", r.URL.Path}
+		if id := r.Header.Get("X-Request-Id"); id != "" {
+			attrs = append(attrs, "request_id", id)
+		}
+
+		r.Set(LoggerKey, base.With(attrs...))
+		r.Next(w)
+	})
+}
+
+// LoggerFromRequest returns the logger stored by WithLogger, or
+// slog.Default() if there isn't one.
+func LoggerFromRequest(r *Request) *slog.Logger {
+	if l, ok := r.Get(LoggerKey).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
@@ -0,0 +1,62 @@
+package robo
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+
+	mux := NewMux()
+	mux.Use(WithLogger(l))
+	mux.Get("/users/{id}", func(w ResponseWriter, r *Request) {
+		LoggerFromRequest(r).Info("hello", "id", r.Param("id"))
+	})
+
+	var tests = []struct {
+		id   string
+		want string
+	}{
+		{"", "level=INFO msg=hello method=GET path=/users/5 id=5\n"},
+		{"abc", "level=INFO msg=hello method=GET path=/users/5 request_id=abc id=5\n"},
+	}
+
+	for _, test := range tests {
+		buf.Reset()
+
+		hr := httptest.NewRequest("GET", "/users/5", nil)
+		if test.id != "" {
+			hr.Header.Set("X-Request-Id", test.id)
+		}
+		mux.ServeHTTP(httptest.NewRecorder(), hr)
+
+		if got := buf.String(); got != test.want {
+			t.Errorf("request ID %q:", test.id)
+			t.Errorf("  got  %q", got)
+			t.Errorf("  want %q", test.want)
+		}
+	}
+}
+
+func TestLoggerFromRequestDefault(t *testing.T) {
+	if l := LoggerFromRequest(new(Request)); l != slog.Default() {
+		t.Errorf("LoggerFromRequest without WithLogger = %v, want slog.Default()", l)
+	}
+
+	var r Request
+	r.Set(LoggerKey, "not a logger")
+	if l := LoggerFromRequest(&r); l != slog.Default() {
+		t.Errorf("LoggerFromRequest with a non-logger value = %v, want slog.Default()", l)
+	}
+}
@@ -2,20 +2,28 @@ package robo
 
 import (
 	"errors"
+	"sort"
+	"strings"
 )
 
 var (
-	errEmptyPattern       = errors.New("robo: empty pattern")
-	errEmptyParameter     = errors.New("robo: empty parameter name")
-	errEmptyCharset       = errors.New("robo: empty charset")
-	errUnexpectedHyphen   = errors.New("robo: unexpected '-'")
-	errUnexpectedLBracket = errors.New("robo: unexpected '['")
-	errUnexpectedRBracket = errors.New("robo: unexpected ']'")
-	errMissingRBrace      = errors.New("robo: missing closing '}'")
-	errMissingRBracket    = errors.New("robo: missing closing ']'")
-	errCharsetHasSlash    = errors.New("robo: parameter charset includes '/'")
-	errImpossibleRange    = errors.New("robo: impossible charset range")
-	errIllegalWildcard    = errors.New("robo: illegal '*' position")
+	errEmptyPattern        = errors.New("robo: empty pattern")
+	errEmptyParameter      = errors.New("robo: empty parameter name")
+	errEmptyCharset        = errors.New("robo: empty charset")
+	errUnexpectedHyphen    = errors.New("robo: unexpected '-'")
+	errUnexpectedLBracket  = errors.New("robo: unexpected '['")
+	errUnexpectedRBracket  = errors.New("robo: unexpected ']'")
+	errMissingRBrace       = errors.New("robo: missing closing '}'")
+	errMissingRBracket     = errors.New("robo: missing closing ']'")
+	errCharsetHasSlash     = errors.New("robo: parameter charset includes '/'")
+	errImpossibleRange     = errors.New("robo: impossible charset range")
+	errIllegalWildcard     = errors.New("robo: illegal '*' position")
+	errTrailingEscape      = errors.New("robo: pattern ends with '\\'")
+	errDuplicateParameter  = errors.New("robo: duplicate parameter name")
+	errReservedParameter   = errors.New("robo: parameter name '*' is reserved for wildcards")
+	errMissingRParen       = errors.New("robo: missing closing ')'")
+	errEmptyAlternative    = errors.New("robo: empty parameter alternative")
+	errAlternativeHasSlash = errors.New("robo: parameter alternative includes '/'")
 )
 
 // The pathMatcher interface is used to match the paths of incoming requests.
@@ -27,33 +35,71 @@ type pathMatcher interface {
 
 // compileMatcher compiles a pathMatcher from a pattern string.
 func compileMatcher(pattern string) (pathMatcher, error) {
+	fs, err := compileFragments(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return newMatcher(fs), nil
+}
+
+// compileFragments splits a pattern string into compiled fragments.
+func compileFragments(pattern string) ([]*fragment, error) {
 	var fs []*fragment
 
 	if pattern == "" {
 		return nil, errEmptyPattern
 	}
 
+	// a trailing "/..." matches anything beginning with the rest of the
+	// pattern, without capturing it
+	subtree := strings.HasSuffix(pattern, "/...")
+	if subtree {
+		pattern = pattern[:len(pattern)-3]
+	}
+
 	for pattern != "" {
 		f, n, err := compileFragment(pattern)
 		if err != nil {
 			return nil, err
 		}
 
+		// a second parameter with the same name would silently shadow
+		// the first one's value
+		if f.t != literalFragment && f.t != wildcardFragment {
+			if f.s == "*" {
+				return nil, errReservedParameter
+			}
+			for _, g := range fs {
+				if g.t != literalFragment && g.s == f.s {
+					return nil, errDuplicateParameter
+				}
+			}
+		}
+
 		fs = append(fs, f)
 		pattern = pattern[n:]
 	}
 
+	if subtree {
+		fs = append(fs, &fragment{t: subtreeFragment})
+	}
+
+	return fs, nil
+}
+
+// newMatcher builds a pathMatcher from a list of compiled fragments.
+func newMatcher(fs []*fragment) pathMatcher {
 	// replace the standard fragmentMatcher with faster equivalents
 	// when possible
 	switch {
 	case len(fs) == 1 && fs[0].t == literalFragment:
-		return &literalMatcher{fs[0].s}, nil
+		return &literalMatcher{fs[0].s}
 	case len(fs) == 2 && fs[0].t == literalFragment &&
 		fs[1].t == wildcardFragment:
-		return &prefixMatcher{fs[0].s, fs[0].n}, nil
+		return &prefixMatcher{fs[0].s, fs[0].n}
 	}
 
-	return &fragmentMatcher{fs}, nil
+	return &fragmentMatcher{fs}
 }
 
 // compileFragment compiles a fragment matcher from a prefix of a pattern
@@ -70,22 +116,34 @@ func compileFragment(pattern string) (*fragment, int, error) {
 	}
 }
 
+// compileLiteralFragment compiles a literal fragment, which extends until
+// the next unescaped '*' or '{'. A backslash makes the character following
+// it literal, and is not itself part of the matched text.
 func compileLiteralFragment(pattern string) (*fragment, int, error) {
 	var i int
 	var e bool
+	var b []byte
 
 	for i = 0; i < len(pattern); i++ {
 		switch c := pattern[i]; {
 		case e:
 			e = false
+			b = append(b, c)
 		case c == '\\':
 			e = true
 		case c == '*', c == '{':
-			return &fragment{t: literalFragment, s: pattern[:i], n: i}, i, nil
+			goto done
+		default:
+			b = append(b, c)
 		}
 	}
 
-	return &fragment{t: literalFragment, s: pattern[:i], n: i}, i, nil
+	if e {
+		return nil, 0, errTrailingEscape
+	}
+
+done:
+	return &fragment{t: literalFragment, s: string(b), n: len(b)}, i, nil
 }
 
 func compileWildcardFragment(pattern string) (*fragment, int, error) {
@@ -122,6 +180,20 @@ func compileParameterFragment(pattern string) (*fragment, int, error) {
 			f := &fragment{t: inclusiveFragment, s: pattern[1:i], r: chars}
 			return f, i + n + 1, nil
 
+		case c == '(':
+			alts, n, err := compileAlternatives(pattern[i:])
+			if err != nil {
+				return nil, 0, err
+			}
+
+			// like charsets, alternatives must be followed by a '}'
+			if i := i + n; i == len(pattern) || pattern[i] != '}' {
+				return nil, 0, errMissingRBrace
+			}
+
+			f := &fragment{t: alternativeFragment, s: pattern[1:i], a: alts}
+			return f, i + n + 1, nil
+
 		case c == '}':
 			if i == 1 {
 				return nil, 0, errEmptyParameter
@@ -129,6 +201,15 @@ func compileParameterFragment(pattern string) (*fragment, int, error) {
 
 			f := &fragment{t: exclusiveFragment, s: pattern[1:i], r: []rune{'/'}}
 
+			// a parameter followed by a '.' and the segment's last
+			// parameter extends to the last '.' in the path segment, so
+			// "/{name}.{ext}" splits "a.b.jpg" into "a.b" and "jpg" (while
+			// "/{a}.{b}.{c}" only applies this to {b})
+			if rest := pattern[i+1:]; strings.HasPrefix(rest, ".{") && lastParameter(rest[1:]) {
+				f.t = stemFragment
+				return f, i + 1, nil
+			}
+
 			// if available, add the next rune to exclusive parameters (for
 			// example: '-' for {foo} in "/{foo}-bar")
 			for _, r := range pattern[i+1:] {
@@ -145,6 +226,21 @@ func compileParameterFragment(pattern string) (*fragment, int, error) {
 	return nil, 0, errMissingRBrace
 }
 
+// lastParameter reports whether the parameter at the start of pattern is
+// the last one in its path segment.
+func lastParameter(pattern string) bool {
+	end := strings.IndexByte(pattern, '}')
+	if end < 0 {
+		return true
+	}
+
+	seg := pattern[end+1:]
+	if n := strings.IndexByte(seg, '/'); n >= 0 {
+		seg = seg[:n]
+	}
+	return !strings.Contains(seg, "{")
+}
+
 func compileCharsetFragment(pattern string) ([]rune, int, error) {
 	var o []rune
 	var e bool
@@ -209,6 +305,51 @@ loop:
 	return nil, 0, errMissingRBracket
 }
 
+// compileAlternatives parses a parenthesized list of '|'-separated literal
+// alternatives, like "(json|csv|xml)". The returned alternatives are sorted
+// by descending length, so that the longest possible match is preferred.
+func compileAlternatives(pattern string) ([]string, int, error) {
+	var alts []string
+	var cur []byte
+	var e bool
+
+	for i := 1; i < len(pattern); i++ {
+		c := pattern[i]
+
+		if !e {
+			switch c {
+			case '\\':
+				e = true
+				continue
+
+			case '|', ')':
+				if len(cur) == 0 {
+					return nil, 0, errEmptyAlternative
+				}
+
+				alts = append(alts, string(cur))
+				cur = nil
+
+				if c == ')' {
+					sort.SliceStable(alts, func(i, j int) bool {
+						return len(alts[i]) > len(alts[j])
+					})
+					return alts, i + 1, nil
+				}
+				continue
+
+			case '/':
+				return nil, 0, errAlternativeHasSlash
+			}
+		}
+
+		cur = append(cur, c)
+		e = false
+	}
+
+	return nil, 0, errMissingRParen
+}
+
 // simplifyCharset merges overlapping rune ranges in the input charset.
 func simplifyCharset(a []rune) []rune {
 	if len(a) == 0 {
@@ -307,13 +448,17 @@ type fragment struct {
 	s string
 	n int
 	r []rune
+	a []string
 }
 
 const (
 	literalFragment = iota
 	exclusiveFragment
+	stemFragment
 	inclusiveFragment
+	alternativeFragment
 	wildcardFragment
+	subtreeFragment
 )
 
 func (f *fragment) matchPrefix(pattern string, buf []string) (int, []string) {
@@ -334,6 +479,18 @@ func (f *fragment) matchPrefix(pattern string, buf []string) (int, []string) {
 		}
 		return nonZero(len(pattern)), append(buf, f.s, pattern)
 
+	case stemFragment:
+		end := strings.IndexByte(pattern, '/')
+		if end < 0 {
+			end = len(pattern)
+		}
+
+		i := strings.LastIndexByte(pattern[:end], '.')
+		if i <= 0 {
+			return -1, nil
+		}
+		return i, append(buf, f.s, pattern[:i])
+
 	case inclusiveFragment:
 		for i, r := range pattern {
 			for j := 0; j < len(f.r); j += 2 {
@@ -346,13 +503,54 @@ func (f *fragment) matchPrefix(pattern string, buf []string) (int, []string) {
 		}
 		return nonZero(len(pattern)), append(buf, f.s, pattern)
 
+	case alternativeFragment:
+		for _, alt := range f.a {
+			if len(pattern) >= len(alt) && pattern[:len(alt)] == alt {
+				return len(alt), append(buf, f.s, alt)
+			}
+		}
+		return -1, nil
+
 	case wildcardFragment:
 		return len(pattern), append(buf, "*", pattern)
+
+	case subtreeFragment:
+		return len(pattern), buf
 	}
 
 	panic("unreachable")
 }
 
+// sameFragments reports whether two lists of fragments match the same
+// paths, ignoring the names of parameters.
+func sameFragments(a, b []*fragment) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		x, y := a[i], b[i]
+		if x.t != y.t || len(x.r) != len(y.r) || len(x.a) != len(y.a) {
+			return false
+		}
+		if x.t == literalFragment && x.s != y.s {
+			return false
+		}
+		for j := range x.r {
+			if x.r[j] != y.r[j] {
+				return false
+			}
+		}
+		for j := range x.a {
+			if x.a[j] != y.a[j] {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
 // nonZero return -1 instead of n if n == 0.
 func nonZero(n int) int {
 	if n == 0 {
@@ -38,6 +38,17 @@ var matcherTests = []struct {
 		{"/foo/bar", true, []string{"*", "bar"}},
 		{"/foo/bar/qux", true, []string{"*", "bar/qux"}},
 	}},
+	{"/admin/...", nil, []matcherCheck{
+		{"/admin", false, nil},
+		{"/admin/", true, nil},
+		{"/admin/users/5", true, nil},
+		{"/administrator", false, nil},
+	}},
+	{"/{org}/...", nil, []matcherCheck{
+		{"/acme", false, nil},
+		{"/acme/", true, []string{"org", "acme"}},
+		{"/acme/repos/x", true, []string{"org", "acme"}},
+	}},
 	{"/{foo}", nil, []matcherCheck{
 		{"/", false, nil},
 		{"/fo", true, []string{"foo", "fo"}},
@@ -66,9 +77,57 @@ var matcherTests = []struct {
 		{"/foo", false, nil},
 		{"/123", false, nil},
 	}},
+	{"/report.{format(json|csv|xml)}", nil, []matcherCheck{
+		{"/report.json", true, []string{"format", "json"}},
+		{"/report.csv", true, []string{"format", "csv"}},
+		{"/report.pdf", false, nil},
+		{"/report.jsonx", false, nil},
+		{"/report.", false, nil},
+	}},
+	{"/{v(v1|v10)}/x", nil, []matcherCheck{
+		{"/v1/x", true, []string{"v", "v1"}},
+		{"/v10/x", true, []string{"v", "v10"}},
+		{"/v2/x", false, nil},
+	}},
+	{"/{name}.{ext}", nil, []matcherCheck{
+		{"/photo.jpg", true, []string{"name", "photo", "ext", "jpg"}},
+		{"/a.b.jpg", true, []string{"name", "a.b", "ext", "jpg"}},
+		{"/photo", false, nil},
+		{"/photo.", false, nil},
+		{"/.jpg", false, nil},
+		{"/a.b/c", false, nil},
+	}},
+	{"/{name}.html", nil, []matcherCheck{
+		{"/a.html", true, []string{"name", "a"}},
+		{"/a.b.html", false, nil},
+	}},
+	{"/{major}.{minor}.{patch}", nil, []matcherCheck{
+		{"/1.2.3", true, []string{"major", "1", "minor", "2", "patch", "3"}},
+		{"/1.2", false, nil},
+	}},
+	{"/{name}.{ext}/raw", nil, []matcherCheck{
+		{"/a.b.jpg/raw", true, []string{"name", "a.b", "ext", "jpg"}},
+		{"/a.jpg/b.raw", false, nil},
+	}},
+	{"/a\\:b/\\{id}", nil, []matcherCheck{
+		{"/a:b/{id}", true, nil},
+		{"/a\\:b/{id}", false, nil},
+		{"/a:b/5", false, nil},
+	}},
+	{"/files/\\*/{name}", nil, []matcherCheck{
+		{"/files/*/x", true, []string{"name", "x"}},
+		{"/files/a/x", false, nil},
+	}},
+	{"/v1\\\\{id}", nil, []matcherCheck{
+		{"/v1\\5", true, []string{"id", "5"}},
+	}},
 
 	{"", errEmptyPattern, nil},
 	{"/*/foo", errIllegalWildcard, nil},
+	{"/foo\\", errTrailingEscape, nil},
+	{"/{id}/{id}", errDuplicateParameter, nil},
+	{"/{id}/{id[0-9]}.{ext}", errDuplicateParameter, nil},
+	{"/{*}", errReservedParameter, nil},
 	{"/{foo", errMissingRBrace, nil},
 	{"/{foo[]}", errEmptyCharset, nil},
 	{"/{foo[}", errMissingRBracket, nil},
@@ -77,6 +136,10 @@ var matcherTests = []struct {
 	{"/{foo[abc[]}", errUnexpectedLBracket, nil},
 	{"/{foo[z-a]}", errImpossibleRange, nil},
 	{"/{foo[a-b-c]}", errUnexpectedHyphen, nil},
+	{"/{foo(a|b}", errMissingRParen, nil},
+	{"/{foo(a||b)}", errEmptyAlternative, nil},
+	{"/{foo(a/b)}", errAlternativeHasSlash, nil},
+	{"/{foo(a|b)", errMissingRBrace, nil},
 }
 
 func TestMatcher(t *testing.T) {
@@ -1,7 +1,24 @@
 package robo
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
 	"net/http"
+	"net/url"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errEmptyMethod    = errors.New("robo: method must not be empty")
+	errNoHandlers     = errors.New("robo: no handlers provided")
+	errInvalidHandler = errors.New("robo: not a valid handler")
 )
 
 // Objects implementing the Handler interface are capable of serving
@@ -29,6 +46,12 @@ func (h *httpHandler) ServeRoboHTTP(w ResponseWriter, r *Request) {
 	h.h.ServeHTTP(w, r.Request)
 }
 
+// FromHTTP adapts an http.Handler for use where a Handler is required. The
+// http.Handler has no way of calling Next, so it always ends the chain.
+func FromHTTP(h http.Handler) Handler {
+	return &httpHandler{h}
+}
+
 // The ResponseWriter type mirrors http.ResponseWriter.
 type ResponseWriter interface {
 	http.ResponseWriter
@@ -38,9 +61,269 @@ type ResponseWriter interface {
 // handlers based on user-provided rules on methods and paths.
 //
 // The zero value for a Mux is a Mux without any registered handlers,
-// ready to use.
+// ready to use. Handlers may be registered, routes modified and options
+// changed while the Mux is serving requests; each request is routed using
+// the handlers and options in place when it arrived.
 type Mux struct {
-	routes []*route
+	mu sync.RWMutex
+	t  *table
+}
+
+// The options type holds the options set through the methods of a Mux.
+type options struct {
+	proxy                bool
+	hideMethodNotAllowed bool
+	strictSlash          bool
+	redirectSlash        int
+	rawPath              bool
+	trusted              []*net.IPNet
+	onPanic              func(w ResponseWriter, r *Request, v interface{})
+	problemJSON          bool
+	produces             string
+	stats                *routeStats
+	trace                *TraceHooks
+}
+
+// The table type holds the routes, handlers and options registered with a
+// Mux. Installed tables are never modified, so that requests can be served
+// from a snapshot while new routes are being registered.
+type table struct {
+	options
+
+	routes     []*Route
+	filters    []func(r *http.Request) bool
+	always     []Handler
+	middleware []Handler
+	notFound   []Handler
+	fallback   []Handler
+}
+
+var emptyTable = new(table)
+
+// snapshot returns the Mux's current table.
+func (m *Mux) snapshot() *table {
+	m.mu.RLock()
+	t := m.t
+	m.mu.RUnlock()
+
+	if t == nil {
+		return emptyTable
+	}
+	return t
+}
+
+// update installs a modified copy of the Mux's current table.
+func (m *Mux) update(fn func(t *table)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t := new(table)
+	if m.t != nil {
+		t.options = m.t.options
+		t.routes = append([]*Route(nil), m.t.routes...)
+		t.filters = append([]func(r *http.Request) bool(nil), m.t.filters...)
+		t.always = append([]Handler(nil), m.t.always...)
+		t.middleware = append([]Handler(nil), m.t.middleware...)
+		t.notFound = m.t.notFound
+		t.fallback = m.t.fallback
+	}
+
+	fn(t)
+	m.t = t
+}
+
+// Reset discards all routes, handlers and options registered with the Mux,
+// returning it to its zero value. Requests already being served are
+// unaffected.
+func (m *Mux) Reset() {
+	m.mu.Lock()
+	m.t = nil
+	m.mu.Unlock()
+}
+
+// Dump returns a textual listing of the Mux's routes, one per line, for use
+// in snapshot tests of a service's routing table. Each line holds the
+// route's methods ("*" for any), its pattern, the number of handlers and
+// any other attributes, like its priority or documentation. Routes of
+// mounted Muxes are listed with the mount prefix prepended to their
+// patterns. Lines are sorted by pattern and then by method, so the output
+// doesn't depend on the order in which routes were registered.
+func (m *Mux) Dump() string {
+	var lines [][2]string
+	m.dump("", 0, &lines)
+
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i][0] != lines[j][0] {
+			return lines[i][0] < lines[j][0]
+		}
+		return lines[i][1] < lines[j][1]
+	})
+
+	var b strings.Builder
+	for _, l := range lines {
+		b.WriteString(l[1])
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// dump appends a (pattern, line) pair for each of the Mux's routes to
+// lines, descending into mounted Muxes.
+func (m *Mux) dump(prefix string, conds int, lines *[][2]string) {
+	for _, r := range m.snapshot().routes {
+		if len(r.handlers) == 1 {
+			if mt, ok := r.handlers[0].(*mount); ok {
+				mt.mux.dump(prefix+mt.prefix, conds+len(r.conds), lines)
+				continue
+			}
+		}
+
+		methods := "*"
+		if r.methods != nil {
+			list := append([]string(nil), r.methods...)
+			sort.Strings(list)
+			methods = strings.Join(list, "|")
+		}
+
+		pattern := prefix + r.pattern.String()
+		line := fmt.Sprintf("%s %s handlers=%d", methods, pattern, len(r.handlers))
+
+		if r.priority != 0 {
+			line += fmt.Sprintf(" priority=%d", r.priority)
+		}
+		if n := conds + len(r.conds); n > 0 {
+			line += fmt.Sprintf(" conditions=%d", n)
+		}
+		if r.maxBody > 0 {
+			line += fmt.Sprintf(" maxbody=%d", r.maxBody)
+		}
+		if d := r.doc; d != nil {
+			if d.summary != "" {
+				line += fmt.Sprintf(" summary=%q", d.summary)
+			}
+			if len(d.tags) > 0 {
+				line += " tags=" + strings.Join(d.tags, ",")
+			}
+		}
+
+		*lines = append(*lines, [2]string{pattern, line})
+	}
+}
+
+// MiddlewareFor returns the names of the handlers which would run ahead of
+// the route with the given method and an equivalent pattern (as defined by
+// HasRoute), in execution order: those registered with Always and Use, in
+// this Mux and in any Muxes mounted along the way to the route. Functions
+// are named as reported by the runtime, like "robo.After.func1", and other
+// handlers by their type. It returns nil if there is no such route.
+func (m *Mux) MiddlewareFor(method, pattern string) []string {
+	fs, err := compileFragments(pattern)
+	if err != nil {
+		return nil
+	}
+
+	chain, ok := m.middlewareFor(method, pattern, fs)
+	if !ok {
+		return nil
+	}
+
+	names := make([]string, len(chain))
+	for i, h := range chain {
+		names[i] = handlerName(h)
+	}
+	return names
+}
+
+// middlewareFor collects the middleware of the Mux and the mounted Muxes
+// leading to a route matching method and the compiled pattern fs.
+func (m *Mux) middlewareFor(method, pattern string, fs []*fragment) ([]Handler, bool) {
+	t := m.snapshot()
+	chain := append(append([]Handler(nil), t.always...), t.middleware...)
+
+	for _, r := range t.routes {
+		if len(r.handlers) == 1 {
+			if mt, ok := r.handlers[0].(*mount); ok {
+				if rest, ok := strings.CutPrefix(pattern, mt.prefix); ok && (rest == "" || rest[0] == '/') {
+					if rest == "" {
+						rest = "/"
+					}
+					if fs, err := compileFragments(rest); err == nil {
+						if sub, ok := mt.mux.middlewareFor(method, rest, fs); ok {
+							return append(chain, sub...), true
+						}
+					}
+				}
+				continue
+			}
+		}
+
+		if r.methods != nil && !r.allows(method) {
+			continue
+		}
+
+		if rfs, err := compileFragments(r.pattern.String()); err == nil && sameFragments(fs, rfs) {
+			return chain, true
+		}
+	}
+
+	return nil, false
+}
+
+// handlerName describes a handler for MiddlewareFor.
+func handlerName(h Handler) string {
+	var v interface{} = h
+	if hh, ok := h.(*httpHandler); ok {
+		v = hh.h
+	}
+
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Func {
+		if fn := runtime.FuncForPC(rv.Pointer()); fn != nil {
+			name := fn.Name()
+			return name[strings.LastIndexByte(name, '/')+1:]
+		}
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// HasRoute reports whether a route with the given method (or "" for routes
+// registered with Any) and an equivalent pattern has been registered. Two
+// patterns are equivalent if they only differ in the names of their
+// parameters, so "/users/{id}" is considered registered if "/users/{uid}"
+// is. Method lists like "GET|POST" must contain the same methods, in any
+// order.
+func (m *Mux) HasRoute(method, pattern string) bool {
+	fs, err := compileFragments(pattern)
+	if err != nil {
+		return false
+	}
+
+	methods, err := splitMethods(method)
+	if err != nil {
+		return false
+	}
+	sort.Strings(methods)
+
+	for _, r := range m.snapshot().routes {
+		if len(r.methods) != len(methods) {
+			continue
+		}
+
+		rm := append([]string(nil), r.methods...)
+		sort.Strings(rm)
+		for i := range rm {
+			if rm[i] != methods[i] {
+				goto next
+			}
+		}
+
+		if rfs, err := compileFragments(r.pattern.String()); err == nil && sameFragments(fs, rfs) {
+			return true
+		}
+
+	next:
+	}
+
+	return false
 }
 
 // NewMux creates a new Mux instance.
@@ -48,52 +331,596 @@ func NewMux() *Mux {
 	return new(Mux)
 }
 
-// Add registers one or more request handlers.
-func (m *Mux) Add(method string, pattern string, handlers ...interface{}) {
+// NewChild creates a new Mux for mounting in m, which starts out with the
+// same path matching options as m: HideMethodNotAllowed, StrictSlash and
+// RedirectSlash. The options are copied, so they can be overridden for the
+// child without affecting m, and later changes to m's options aren't
+// reflected in the child. The child Muxes returned by Host, Header and
+// Version are created this way.
+func (m *Mux) NewChild() *Mux {
+	t := m.snapshot()
+	return &Mux{t: &table{options: options{
+		hideMethodNotAllowed: t.hideMethodNotAllowed,
+		strictSlash:          t.strictSlash,
+		redirectSlash:        t.redirectSlash,
+	}}}
+}
+
+// ProxyMode controls whether the Mux should expect absolute-form request
+// URIs (as in "GET http://example.com/foo HTTP/1.1"), which is how requests
+// are sent to proxies. When enabled, the host component of such a URI takes
+// precedence over the Host header, for routing as well as for handlers.
+//
+// The http.Server already does this for the requests it parses, so the
+// option only matters for requests built or rewritten by other code, like
+// a handler which sets the Host field but leaves an absolute URL in place.
+// The caller's request is never modified; if the hosts differ, the Mux
+// routes a copy of it instead.
+func (m *Mux) ProxyMode(enabled bool) {
+	m.update(func(t *table) {
+		t.proxy = enabled
+	})
+}
+
+// HideMethodNotAllowed controls whether requests with a path matching one
+// or more routes, just not for the request's method, should be treated as
+// if no route matched at all. When enabled, such requests are served by the
+// NotFound handlers (or a plain 404) instead of receiving a 405 response
+// with an Allow header.
+func (m *Mux) HideMethodNotAllowed(enabled bool) {
+	m.update(func(t *table) {
+		t.hideMethodNotAllowed = enabled
+	})
+}
+
+// StrictSlash controls whether paths with and without a trailing slash
+// (like "/users" and "/users/") should be treated as equivalent. When
+// enabled, a route which doesn't match a request's path is also tested
+// against the path with its trailing slash added or removed, and serves
+// the request directly if that matches. Either way, PatternFromRequest
+// reports the pattern as it was registered. It is disabled by default.
+func (m *Mux) StrictSlash(enabled bool) {
+	m.update(func(t *table) {
+		t.strictSlash = enabled
+	})
+}
+
+// RedirectSlash makes the Mux redirect requests which don't match any
+// route, but would if their path's trailing slash was added or removed, to
+// that path. With code 301 (or 308) the redirect is permanent, and with 302
+// (or 307) it is temporary; GET and HEAD requests receive the former of
+// each pair, other methods the latter, so that clients preserve the method
+// and body. A code of 0, the default, disables redirects. StrictSlash
+// takes precedence, since it serves such requests directly.
+func (m *Mux) RedirectSlash(code int) {
+	switch code {
+	case 0:
+	case 301, 308:
+		code = 301
+	case 302, 307:
+		code = 302
+	default:
+		panic("robo: invalid redirect status")
+	}
+	m.update(func(t *table) {
+		t.redirectSlash = code
+	})
+}
+
+// DecodeBeforeMatch controls whether routes are matched against the
+// request's decoded path (URL.Path), which is the default, or its raw,
+// percent-encoded form (URL.EscapedPath()). Matching the raw path lets a
+// pattern tell an encoded slash ("%2F") apart from a real one, so that
+// "/files/{name}" matches "/files/a%2Fb".
+//
+// Parameters are always decoded before being handed to handlers, so in
+// the example above the "name" parameter holds "a/b" either way. Muxes
+// mounted in this one match against the same form of the path as it.
+func (m *Mux) DecodeBeforeMatch(enabled bool) {
+	m.update(func(t *table) {
+		t.rawPath = !enabled
+	})
+}
+
+// DefaultProduces sets a Content-Type for responses whose handlers don't
+// set one, so that net/http doesn't have to guess it from the body. It is
+// applied when the response header is committed, which leaves handlers
+// free to set a type of their own, and is skipped for 204 and 304
+// responses. Unlike Route.Produces, it covers every route of the Mux, as
+// well as its NotFound and Fallback handlers. An empty contentType, the
+// default, disables it.
+func (m *Mux) DefaultProduces(contentType string) {
+	m.update(func(t *table) {
+		t.produces = contentType
+	})
+}
+
+// TrustProxies sets the networks (in CIDR notation, or as plain IP
+// addresses) of proxies trusted to report the original scheme of requests
+// through the X-Forwarded-Proto header, as used by Scheme.
+func (m *Mux) TrustProxies(networks ...string) {
+	m.update(func(t *table) {
+		t.trusted = parseNetworks(networks)
+	})
+}
+
+// ProblemJSON controls whether the default OnPanic handler responds with
+// RFC 7807 problem details (see Problem) rather than plain text, for APIs
+// whose clients expect errors in that format. The status code and message
+// of panicked StatusCoder values, like *Error, become the problem's status
+// and detail. It is disabled by default.
+func (m *Mux) ProblemJSON(enabled bool) {
+	m.update(func(t *table) {
+		t.problemJSON = enabled
+	})
+}
+
+// OnPanic installs a function which is called with the recovered value
+// when serving a request panics, whether in a handler, the NotFound and
+// Fallback handlers, or the routing logic itself. If fn is nil, a plain
+// 500 response is sent instead, unless the value implements StatusCoder,
+// in which case the response has its status code and message (see also
+// ProblemJSON). Panics with http.ErrAbortHandler are left alone, since
+// they are used to abort a response deliberately.
+//
+// Only the outermost Mux's panic handler applies to requests routed
+// through mounted Muxes.
+func (m *Mux) OnPanic(fn func(w ResponseWriter, r *Request, v interface{})) {
+	if fn == nil {
+		fn = func(w ResponseWriter, r *Request, v interface{}) {
+			problemJSON := m.snapshot().problemJSON
+
+			switch sc, ok := v.(StatusCoder); {
+			case problemJSON && ok:
+				Problem(w, sc.StatusCode(), "", fmt.Sprint(v))
+			case problemJSON:
+				Problem(w, 500, "", "")
+			case ok:
+				http.Error(w, fmt.Sprint(v)+"\n", sc.StatusCode())
+			default:
+				http.Error(w, "Internal server error.\n", 500)
+			}
+		}
+	}
+	m.update(func(t *table) {
+		t.onPanic = fn
+	})
+}
+
+// NotFound registers one or more handlers to be invoked when no route
+// matches an incoming request. If the last handler calls Next, a plain
+// 404 response is sent.
+func (m *Mux) NotFound(handlers ...interface{}) {
+	clean, err := adaptHandlers(handlers)
+	if err != nil {
+		panic(err)
+	}
+	m.update(func(t *table) {
+		t.notFound = clean
+	})
+}
+
+// Fallback registers one or more handlers to be invoked for any request
+// which couldn't be routed, taking the place of both the NotFound handlers
+// and the default 405 response. A *RoutingFailure describing why routing
+// failed is stored in the request's data store under FailureKey.
+func (m *Mux) Fallback(handlers ...interface{}) {
+	clean, err := adaptHandlers(handlers)
+	if err != nil {
+		panic(err)
+	}
+	m.update(func(t *table) {
+		t.fallback = clean
+	})
+}
+
+// FailureKey is the data store key under which Fallback handlers can find
+// a *RoutingFailure.
+const FailureKey = "robo.failure"
+
+// The RoutingFailure type describes why a request couldn't be routed.
+type RoutingFailure struct {
+	// Status is 405 if one or more routes matched the request's path, but
+	// not its method (unless HideMethodNotAllowed is enabled), and 404
+	// otherwise.
+	Status int
+
+	// Allowed lists the methods of routes matching the request's path.
+	// It is only guaranteed to be populated when Status is 405.
+	Allowed []string
+}
+
+// Use registers one or more middleware handlers, which run ahead of the
+// handlers of the first route matching a request. Middleware handlers are
+// expected to call Next to continue processing.
+//
+// Middleware registered with a mounted Mux only runs for requests routed
+// into it, and after the parent Mux's own middleware.
+func (m *Mux) Use(handlers ...interface{}) {
+	clean, err := adaptHandlers(handlers)
+	if err != nil {
+		panic(err)
+	}
+	m.update(func(t *table) {
+		t.middleware = append(t.middleware, clean...)
+	})
+}
+
+// Filter registers a function which screens requests before any routes are
+// tested. Requests it rejects are handed straight to the NotFound handlers
+// (or Fallback handlers) without being matched against any routes, which
+// makes it a cheap way of turning away obviously bad requests. Filters run
+// in registration order. Handlers registered with Always still see rejected
+// requests.
+func (m *Mux) Filter(fn func(r *http.Request) bool) {
+	m.update(func(t *table) {
+		t.filters = append(t.filters, fn)
+	})
+}
+
+// Always registers one or more handlers to be invoked for every request,
+// ahead of routing, including requests which end up with a 404 or 405
+// response. This is useful for things like access logging. The handlers
+// call Next to have the request routed as usual. Unlike Use, handlers
+// registered with Always don't have access to any route parameters.
+func (m *Mux) Always(handlers ...interface{}) {
+	clean, err := adaptHandlers(handlers)
+	if err != nil {
+		panic(err)
+	}
+	m.update(func(t *table) {
+		t.always = append(t.always, clean...)
+	})
+}
+
+// Mount routes all requests with a path equal to, or beginning with, prefix
+// followed by a slash, to a child Mux. The child matches its routes against
+// the remainder of the path (or "/" when nothing remains). Parameters
+// captured by the parent remain available through the child's requests,
+// and requests the child can't route fall through to the parent's
+// remaining routes unless the child has failure handlers of its own.
+//
+// The prefix is stripped when a request is dispatched, so the same child
+// can be mounted at several prefixes (or in several Muxes), sharing its
+// routes between them.
+func (m *Mux) Mount(prefix string, child *Mux) {
+	prefix = strings.TrimRight(prefix, "/")
+
+	p, err := CompilePattern(prefix + "/*")
+	if err != nil {
+		panic(err)
+	}
+
+	m.insert(&Route{
+		pattern:  p,
+		matcher:  &mountMatcher{prefix},
+		handlers: []Handler{&mount{prefix, child}},
+	})
+}
+
+// MountHTTP routes requests with a path equal to or under prefix to h, like
+// Mount, with the prefix stripped from the request's URL.Path (and RawPath)
+// as by http.StripPrefix. An empty remainder is passed on as "/". Since h
+// has no way of calling Next, requests never fall through to routes
+// registered after it.
+func (m *Mux) MountHTTP(prefix string, h http.Handler) {
+	prefix = strings.TrimRight(prefix, "/")
+
+	p, err := CompilePattern(prefix + "/*")
+	if err != nil {
+		panic(err)
+	}
+
+	m.insert(&Route{
+		pattern:  p,
+		matcher:  &mountMatcher{prefix},
+		handlers: []Handler{&httpMount{prefix, h}},
+	})
+}
+
+// Host returns a child Mux serving requests with a Host header matching
+// pattern, compared case-insensitively and ignoring any port. The pattern
+// uses the same syntax as paths, so "{tenant}.example.com" captures the
+// first label of the host as a parameter available to the child's routes.
+//
+// Like a mounted Mux, the child hands requests it can't route back to the
+// parent unless it has NotFound or Fallback handlers of its own, which
+// makes it possible to have host-specific 404 pages.
+func (m *Mux) Host(pattern string) *Mux {
+	hm, err := compileMatcher(strings.ToLower(pattern))
+	if err != nil {
+		panic(err)
+	}
+
+	return m.child(func(hr *http.Request, buf []string) (bool, []string) {
+		return hm.match(requestHost(hr), buf)
+	})
+}
+
+// Scheme returns a child Mux serving requests with the given effective
+// scheme ("http" or "https"). A request is considered secure if it was
+// received over TLS, or if a trusted proxy (see TrustProxies) says so
+// through the X-Forwarded-Proto header. As with Host, requests the child
+// can't route are handed back to the parent.
+func (m *Mux) Scheme(scheme string) *Mux {
+	scheme = strings.ToLower(scheme)
+
+	return m.child(func(hr *http.Request, buf []string) (bool, []string) {
+		return requestScheme(hr, m.snapshot().trusted) == scheme, buf
+	})
+}
+
+// Header returns a child Mux serving requests which carry a non-empty
+// header called name. The header's value is captured as the parameter
+// param, making it available to the child's routes. Requests without the
+// header are handed back to the parent, as with Host.
+func (m *Mux) Header(name, param string) *Mux {
+	name = http.CanonicalHeaderKey(name)
+
+	return m.child(func(hr *http.Request, buf []string) (bool, []string) {
+		v := hr.Header.Get(name)
+		if v == "" {
+			return false, nil
+		}
+		return true, append(buf, param, v)
+	})
+}
+
+// Version returns a child Mux serving all requests, with an optional
+// leading path segment naming one of the given versions (as in "/v2/users")
+// stripped from the path its routes are matched against. The version is
+// captured as the "version" parameter, which holds def for requests without
+// one. As with Mount, requests the child can't route fall through to the
+// parent.
+func (m *Mux) Version(def string, versions ...string) *Mux {
+	child := m.NewChild()
+	vm := &versionMatcher{def, versions}
+
+	m.insert(&Route{
+		pattern:  wildcardPattern,
+		matcher:  vm,
+		handlers: []Handler{&versionMount{vm, child}},
+	})
+
+	return child
+}
+
+// child registers a child Mux serving all requests meeting a condition.
+func (m *Mux) child(cond condition) *Mux {
+	child := m.NewChild()
+
+	m.insert(&Route{
+		pattern:  wildcardPattern,
+		matcher:  &mountMatcher{""},
+		handlers: []Handler{&mount{"", child}},
+		conds:    []condition{cond},
+	})
+
+	return child
+}
+
+// Add registers one or more request handlers, returning the new Route. The
+// method may be a '|'-separated list, like "GET|POST", in which case the
+// route matches any of the listed methods.
+func (m *Mux) Add(method string, pattern string, handlers ...interface{}) *Route {
 	if method == "" {
-		panic("method must not be empty")
+		panic(errEmptyMethod)
 	}
-	m.add(method, pattern, handlers...)
+	return m.add(method, pattern, handlers...)
+}
+
+// Exact registers one or more request handlers for a literal path, like
+// Add, but without parsing it as a pattern. Characters like '*' and '{'
+// have no special meaning, and the route matches the path exactly.
+func (m *Mux) Exact(method, path string, handlers ...interface{}) *Route {
+	if method == "" {
+		panic(errEmptyMethod)
+	} else if path == "" {
+		panic(errEmptyPattern)
+	}
+
+	clean, err := adaptHandlers(handlers)
+	if err != nil {
+		panic(err)
+	}
+
+	methods, err := splitMethods(method)
+	if err != nil {
+		panic(err)
+	}
+
+	p := &Pattern{path, []Segment{{LiteralSegment, path}}, &literalMatcher{path}}
+	r := &Route{method: method, methods: methods, pattern: p, matcher: p.matcher, handlers: clean}
+
+	m.insert(r)
+	return r
+}
+
+// AddMatcher registers one or more request handlers for requests accepted
+// by a custom matching function, which may also return parameters to be
+// made available through Param. Such routes match any method and path, and
+// are tested after all other routes, in registration order.
+func (m *Mux) AddMatcher(match func(r *http.Request) (bool, map[string]string), handlers ...interface{}) *Route {
+	clean, err := adaptHandlers(handlers)
+	if err != nil {
+		panic(err)
+	}
+
+	r := &Route{
+		pattern:  wildcardPattern,
+		matcher:  &mountMatcher{""},
+		handlers: clean,
+		priority: math.MinInt,
+		conds: []condition{func(hr *http.Request, buf []string) (bool, []string) {
+			ok, params := match(hr)
+			if !ok {
+				return false, nil
+			}
+
+			names := make([]string, 0, len(params))
+			for name := range params {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			for _, name := range names {
+				buf = append(buf, name, params[name])
+			}
+			return true, buf
+		}},
+	}
+
+	m.insert(r)
+	return r
+}
+
+// AddPatterns registers the same request handlers under several patterns,
+// like repeated calls to Add. No routes are added if any of the patterns
+// are invalid.
+func (m *Mux) AddPatterns(method string, patterns []string, handlers ...interface{}) []*Route {
+	if method == "" {
+		panic(errEmptyMethod)
+	}
+
+	clean, err := adaptHandlers(handlers)
+	if err != nil {
+		panic(err)
+	}
+
+	routes := make([]*Route, len(patterns))
+	for i, pattern := range patterns {
+		if routes[i], err = newRoute(method, pattern, clean); err != nil {
+			panic(err)
+		}
+	}
+
+	for _, r := range routes {
+		m.insert(r)
+	}
+
+	return routes
+}
+
+// AddIf registers one or more request handlers, like Add, if cond is true,
+// and does nothing otherwise. It returns the Mux to allow chaining.
+func (m *Mux) AddIf(cond bool, method, pattern string, handlers ...interface{}) *Mux {
+	if cond {
+		m.Add(method, pattern, handlers...)
+	}
+	return m
+}
+
+// TryAdd registers one or more request handlers, like Add, but returns an
+// error instead of panicking if the pattern or handlers are invalid. This
+// is useful when patterns come from configuration or user input.
+func (m *Mux) TryAdd(method string, pattern string, handlers ...interface{}) error {
+	if method == "" {
+		return errEmptyMethod
+	}
+	_, err := m.tryAdd(0, method, pattern, handlers)
+	return err
+}
+
+// AddWithPriority registers one or more request handlers, like Add, but
+// with an explicit priority. Routes are tested in order of descending
+// priority, and in registration order among routes of equal priority.
+// Routes registered by other methods have a priority of 0.
+func (m *Mux) AddWithPriority(priority int, method, pattern string, handlers ...interface{}) *Route {
+	if method == "" {
+		panic(errEmptyMethod)
+	}
+
+	r, err := m.tryAdd(priority, method, pattern, handlers)
+	if err != nil {
+		panic(err)
+	}
+
+	return r
 }
 
 // Any registers one or more request handlers matching any HTTP method.
-func (m *Mux) Any(pattern string, handlers ...interface{}) {
-	m.add("", pattern, handlers...)
+func (m *Mux) Any(pattern string, handlers ...interface{}) *Route {
+	return m.add("", pattern, handlers...)
 }
 
 // Delete registers one or more DELETE handlers.
-func (m *Mux) Delete(pattern string, handlers ...interface{}) {
-	m.add("DELETE", pattern, handlers...)
+func (m *Mux) Delete(pattern string, handlers ...interface{}) *Route {
+	return m.add("DELETE", pattern, handlers...)
 }
 
 // Get registers one or more GET handlers.
-func (m *Mux) Get(pattern string, handlers ...interface{}) {
-	m.add("GET", pattern, handlers...)
+func (m *Mux) Get(pattern string, handlers ...interface{}) *Route {
+	return m.add("GET", pattern, handlers...)
 }
 
 // Patch registers one or more PATCH handlers.
-func (m *Mux) Patch(pattern string, handlers ...interface{}) {
-	m.add("PATCH", pattern, handlers...)
+func (m *Mux) Patch(pattern string, handlers ...interface{}) *Route {
+	return m.add("PATCH", pattern, handlers...)
 }
 
 // Post registers one or more POST handlers.
-func (m *Mux) Post(pattern string, handlers ...interface{}) {
-	m.add("POST", pattern, handlers...)
+func (m *Mux) Post(pattern string, handlers ...interface{}) *Route {
+	return m.add("POST", pattern, handlers...)
 }
 
 // Put registers one or more PUT handlers.
-func (m *Mux) Put(pattern string, handlers ...interface{}) {
-	m.add("PUT", pattern, handlers...)
+func (m *Mux) Put(pattern string, handlers ...interface{}) *Route {
+	return m.add("PUT", pattern, handlers...)
 }
 
 // add registers a set of handlers for the given HTTP method ("" matching
 // any method) and URL pattern.
-func (m *Mux) add(method, pattern string, handlers ...interface{}) {
+func (m *Mux) add(method, pattern string, handlers ...interface{}) *Route {
+	r, err := m.tryAdd(0, method, pattern, handlers)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// tryAdd is the error-returning equivalent of add, with a priority.
+func (m *Mux) tryAdd(priority int, method, pattern string, handlers []interface{}) (*Route, error) {
+	clean, err := adaptHandlers(handlers)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := newRoute(method, pattern, clean)
+	if err != nil {
+		return nil, err
+	}
+
+	r.priority = priority
+	m.insert(r)
+	return r, nil
+}
+
+// insert adds a route after all routes of equal or higher priority. The
+// table gets a copy of r, so that r's methods can modify it without
+// affecting requests being served.
+func (m *Mux) insert(r *Route) {
+	r.mux = m
+	r.live = r.clone()
+
+	m.update(func(t *table) {
+		i := len(t.routes)
+		for i > 0 && t.routes[i-1].priority < r.priority {
+			i--
+		}
+
+		t.routes = append(t.routes, nil)
+		copy(t.routes[i+1:], t.routes[i:])
+		t.routes[i] = r.live
+	})
+}
+
+// adaptHandlers validates a non-empty set of handlers, converting them to
+// implementations of the Handler interface.
+func adaptHandlers(handlers []interface{}) ([]Handler, error) {
 	if len(handlers) == 0 {
-		panic("no handlers provided")
+		return nil, errNoHandlers
 	}
 
-	// validate the provided set of handlers
 	clean := make([]Handler, 0, len(handlers))
 
 	for _, h := range handlers {
@@ -107,27 +934,183 @@ func (m *Mux) add(method, pattern string, handlers ...interface{}) {
 		case func(w http.ResponseWriter, r *http.Request):
 			clean = append(clean, &httpHandler{http.HandlerFunc(h)})
 		default:
-			panic("not a valid handler")
+			return nil, errInvalidHandler
 		}
 	}
 
-	m.routes = append(m.routes, newRoute(method, pattern, clean))
+	return clean, nil
 }
 
 // newRoute initializes a new route.
-func newRoute(method, pattern string, handlers []Handler) *route {
-	matcher, err := compileMatcher(pattern)
+func newRoute(method, pattern string, handlers []Handler) (*Route, error) {
+	methods, err := splitMethods(method)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+
+	p, err := CompilePattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Route{method: method, methods: methods, pattern: p, matcher: p.matcher, handlers: handlers}, nil
+}
+
+// splitMethods splits a '|'-separated list of methods, like "GET|POST". The
+// empty string stands for any method, and results in a nil list.
+func splitMethods(method string) ([]string, error) {
+	if method == "" {
+		return nil, nil
+	}
+
+	methods := strings.Split(method, "|")
+	for _, m := range methods {
+		if m == "" {
+			return nil, errEmptyMethod
+		}
 	}
 
-	return &route{method, matcher, handlers}
+	return methods, nil
 }
 
 // ServeRoboHTTP dispatches the request to matching routes registered with
 // the Mux instance.
 func (m *Mux) ServeRoboHTTP(w ResponseWriter, r *Request) {
-	(&queue{routes: m.routes}).serveNext(w, r.Request)
+	t := m.snapshot()
+
+	if t.onPanic != nil {
+		defer func() {
+			if v := recover(); v != nil {
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+				t.onPanic(w, r, v)
+			}
+		}()
+	}
+
+	hr := r.Request
+	path := hr.URL.Path
+	if t.rawPath {
+		path = hr.URL.EscapedPath()
+	}
+
+	if t.proxy && hr.URL.IsAbs() && hr.URL.Host != "" && hr.URL.Host != hr.Host {
+		// route a copy, leaving the caller's request alone
+		c := *hr
+		c.Host = hr.URL.Host
+
+		rr := *r
+		rr.Request = &c
+		r = &rr
+	}
+
+	// requests with an empty path (which net/http never produces, but
+	// hand-built requests might have) are routed as if it were "/"
+	if path == "" {
+		path = "/"
+	}
+
+	m.serve(w, r, path, t.rawPath)
+}
+
+// serve dispatches a request to the Mux's routes, matching them against
+// path, which is in its raw, percent-encoded form if raw is set. When r was
+// created by another Mux, its data store is shared, and if the Mux has no
+// failure handlers of its own, requests which can't be routed are handed
+// back to the other Mux by calling r.Next.
+func (m *Mux) serve(w ResponseWriter, r *Request, path string, raw bool) {
+	t := m.snapshot()
+	q := &queue{mux: m, table: t, routes: t.routes, path: path, raw: raw}
+
+	if r.queue != nil {
+		q.parent = r
+	}
+
+	if r.store != nil {
+		q.store = r.store
+	} else {
+		q.store = &q.local
+	}
+
+	for _, fn := range t.filters {
+		if !fn(r.Request) {
+			// skip routing, including the check for a 405 response
+			q.routes, q.matched = nil, true
+			break
+		}
+	}
+
+	if len(t.always) > 0 {
+		q.begin(nil, t.always, emptyParams, nil)
+	}
+
+	if t.produces != "" {
+		w = produce(w, t.produces)
+	}
+
+	if t.stats == nil {
+		q.serveNext(w, r.Request)
+		return
+	}
+
+	hw := newHookWriter(w, nil)
+	q.serveNext(hw.writer(), r.Request)
+
+	if q.route != nil {
+		hw.commit(200)
+		t.stats.record(q.route.pattern.String(), hw.status)
+	}
+}
+
+// produce wraps w, setting the response's Content-Type to contentType as
+// the header is committed, unless it has already been set or the status
+// implies the response has no body.
+func produce(w ResponseWriter, contentType string) ResponseWriter {
+	var hw *hookWriter
+	hw = newHookWriter(w, func() {
+		if hw.status == 204 || hw.status == 304 {
+			return
+		}
+		if h := hw.Header(); h.Get("Content-Type") == "" {
+			h.Set("Content-Type", contentType)
+		}
+	})
+	return hw.writer()
+}
+
+// allowed returns a sorted list of the methods explicitly registered for
+// routes in t matching a request and path, excluding the request's method.
+// Hidden routes are left out.
+func (m *Mux) allowed(t *table, hr *http.Request, path string) []string {
+	var list []string
+
+	for _, r := range t.routes {
+		if r.methods == nil || r.hidden || r.allows(hr.Method) {
+			continue
+		}
+
+		ok, _ := r.match(hr, path)
+		if !ok && t.strictSlash {
+			ok, _ = r.match(hr, toggleSlash(path))
+		}
+		if !ok {
+			continue
+		}
+
+	outer:
+		for _, method := range r.methods {
+			for _, seen := range list {
+				if method == seen {
+					continue outer
+				}
+			}
+			list = append(list, method)
+		}
+	}
+
+	sort.Strings(list)
+	return list
 }
 
 // ServeHTTP dispatches the request to matching routes registered with
@@ -136,51 +1119,480 @@ func (m *Mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	m.ServeRoboHTTP(w, &Request{Request: r})
 }
 
-// The route type describes a registered route.
-type route struct {
+// wildcardPattern matches any path.
+var wildcardPattern, _ = CompilePattern("*")
+
+// requestHost returns the lower-cased host of a request, without a port.
+func requestHost(hr *http.Request) string {
+	host := hr.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
+
+// mountMatcher matches paths equal to, or beginning with, a prefix followed
+// by a slash.
+type mountMatcher struct {
+	prefix string
+}
+
+func (mm *mountMatcher) match(path string, buf []string) (bool, []string) {
+	n := len(mm.prefix)
+	if len(path) >= n && path[:n] == mm.prefix && (len(path) == n || path[n] == '/') {
+		return true, buf
+	}
+	return false, nil
+}
+
+// The mount type dispatches requests to a mounted Mux.
+type mount struct {
+	prefix string
+	mux    *Mux
+}
+
+func (h *mount) ServeRoboHTTP(w ResponseWriter, r *Request) {
+	path := r.queue.path[len(h.prefix):]
+	if path == "" {
+		path = "/"
+	}
+	h.mux.serve(w, r, path, r.queue.raw)
+}
+
+// The httpMount type dispatches requests to an http.Handler, with a path
+// prefix stripped.
+type httpMount struct {
+	prefix string
+	h      http.Handler
+}
+
+func (h *httpMount) ServeRoboHTTP(w ResponseWriter, r *Request) {
+	// strip the prefix from the path the route was matched against, which
+	// lacks the prefixes of any outer mounts
+	rest := r.queue.path[len(h.prefix):]
+
+	hr := *r.Request
+	u := *hr.URL
+	if r.queue.raw {
+		path, err := url.PathUnescape(rest)
+		if err != nil {
+			http.Error(w, "Bad request.\n", 400)
+			return
+		}
+		u.Path, u.RawPath = path, rest
+	} else {
+		u.Path, u.RawPath = rest, rawSuffix(u.EscapedPath(), rest)
+	}
+	if u.Path == "" {
+		u.Path, u.RawPath = "/", ""
+	}
+	hr.URL = &u
+
+	h.h.ServeHTTP(w, &hr)
+}
+
+// rawSuffix returns the suffix of the escaped path raw which decodes to
+// path, for use as a URL's RawPath. It returns "" if the suffix is path
+// itself, or if there is none.
+func rawSuffix(raw, path string) string {
+	for i := len(raw) - len(path); i >= 0; i-- {
+		if s, err := url.PathUnescape(raw[i:]); err == nil && s == path {
+			if raw[i:] == path {
+				return ""
+			}
+			return raw[i:]
+		}
+	}
+	return ""
+}
+
+// versionMatcher matches all paths, capturing an optional leading version
+// segment.
+type versionMatcher struct {
+	def      string
+	versions []string
+}
+
+// split separates a version segment from the rest of the path.
+func (vm *versionMatcher) split(path string) (string, string) {
+	seg, rest := path, "/"
+	if n := strings.IndexByte(path[1:], '/'); n >= 0 {
+		seg, rest = path[:n+1], path[n+1:]
+	}
+
+	for _, v := range vm.versions {
+		if seg[1:] == v {
+			return v, rest
+		}
+	}
+
+	return vm.def, path
+}
+
+func (vm *versionMatcher) match(path string, buf []string) (bool, []string) {
+	if path == "" || path[0] != '/' {
+		return false, nil
+	}
+
+	v, _ := vm.split(path)
+	return true, append(buf, "version", v)
+}
+
+// The versionMount type dispatches requests to a Mux created by Version.
+type versionMount struct {
+	vm  *versionMatcher
+	mux *Mux
+}
+
+func (h *versionMount) ServeRoboHTTP(w ResponseWriter, r *Request) {
+	_, path := h.vm.split(r.queue.path)
+	h.mux.serve(w, r, path, r.queue.raw)
+}
+
+// Subrouter returns a handler which dispatches requests to sub, for use
+// with routes whose pattern ends in a wildcard. The part of the path
+// captured by the wildcard is what sub matches its routes against, and
+// the request's URL.Path is rewritten to match. Unlike Mount, Subrouter is
+// an ordinary handler, so it can be combined with other handlers and route
+// options. Parameters captured by the outer route remain available, and
+// requests sub can't route fall through to the outer Mux. Since the
+// captured path has already been decoded, sub always matches against the
+// decoded form, even if the outer Mux matches raw paths (see
+// DecodeBeforeMatch).
+func Subrouter(sub *Mux) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		path := RemainingPath(r)
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+
+		hr := *r.Request
+		u := *hr.URL
+		u.Path, u.RawPath = path, ""
+		hr.URL = &u
+
+		rr := *r
+		rr.Request = &hr
+		// the remaining path has already been decoded
+		sub.serve(w, &rr, path, false)
+	})
+}
+
+// A Route describes a registered route. The methods of a Route modify it,
+// and return it to allow chaining. Like registration, they are safe to use
+// while the Mux is serving requests; requests already being served are
+// unaffected.
+type Route struct {
 	method   string
+	methods  []string
+	pattern  *Pattern
 	matcher  pathMatcher
 	handlers []Handler
+	priority int
+	conds    []condition
+	doc      *routeDoc
+	maxBody  int64
+	hidden   bool // left out of Allow headers
+
+	// the Mux the route is registered with, and the copy of it in the
+	// Mux's current table
+	mux  *Mux
+	live *Route
 }
 
+// A condition is a requirement for a route to match a request, in addition
+// to its method and path. Any parameters it captures are appended to buf.
+type condition func(hr *http.Request, buf []string) (bool, []string)
+
 var emptyParams = make(map[string]string)
 
-// check tests whether the route matches a provided method and path. The
-// parameter map will always be non-nil when the first is true.
-func (r *route) check(method, path string) (bool, map[string]string) {
-	if method != r.method && r.method != "" {
-		return false, nil
+// Params returns the names of the parameters captured by the route, in the
+// order they appear in its pattern. The list is empty for static routes.
+func (r *Route) Params() []string {
+	return append([]string{}, r.pattern.Params()...)
+}
+
+// Timeout wraps the route's handlers with the Timeout middleware.
+func (r *Route) Timeout(d time.Duration) *Route {
+	return r.wrap(Timeout(d))
+}
+
+// MaxBody limits the size of the bodies of requests served by the route to
+// n bytes, like the MaxBytes middleware, except that requests declaring a
+// larger Content-Length are rejected with a 413 right away. The limit
+// takes the place of any limit set with MaxBytes, including by a Mux the
+// route's Mux is mounted in.
+func (r *Route) MaxBody(n int64) *Route {
+	r.maxBody = n
+	return r.wrap(HandlerFunc(func(w ResponseWriter, req *Request) {
+		limitBody(w, req, n)
+	}))
+}
+
+// Produces sets the response's Content-Type header to contentType before
+// the route's handlers run, unless it has already been set (by middleware,
+// for example). The handlers remain free to replace it.
+func (r *Route) Produces(contentType string) *Route {
+	return r.wrap(HandlerFunc(func(w ResponseWriter, req *Request) {
+		if h := w.Header(); h.Get("Content-Type") == "" {
+			h.Set("Content-Type", contentType)
+		}
+		req.Next(w)
+	}))
+}
+
+// Value stores val under key in the request's data store before the
+// route's handlers are invoked, which is useful for tagging routes with
+// things like a resource type or a required permission.
+func (r *Route) Value(key string, val interface{}) *Route {
+	return r.wrap(HandlerFunc(func(w ResponseWriter, req *Request) {
+		req.Set(key, val)
+		req.Next(w)
+	}))
+}
+
+// Validate adds a check of the named parameter's value, run by fn before
+// the route's handlers are invoked. If fn returns an error, a plain 400
+// response is sent instead. Unlike a charset or alternatives in the pattern,
+// a failed validation doesn't make the route any less of a match, so other
+// routes aren't tried. Parameters which weren't captured aren't validated.
+func (r *Route) Validate(param string, fn func(value string) error) *Route {
+	return r.wrap(HandlerFunc(func(w ResponseWriter, req *Request) {
+		if v, ok := lookupParam(req, param); ok {
+			if err := fn(v); err != nil {
+				http.Error(w, "Bad request.\n", 400)
+				return
+			}
+		}
+		req.Next(w)
+	}))
+}
+
+// A MatcherFunc is an additional requirement for a route to match a
+// request, which can be attached with Route.Match.
+type MatcherFunc func(r *http.Request) bool
+
+// Match adds a requirement for the route to match a request. Requests it
+// rejects are tested against the remaining routes, as if the route's
+// pattern hadn't matched.
+func (r *Route) Match(fn MatcherFunc) *Route {
+	r.conds = append(r.conds, func(hr *http.Request, buf []string) (bool, []string) {
+		return fn(hr), buf
+	})
+	return r.publish()
+}
+
+// ProtoAtLeast returns a MatcherFunc which requires requests to use at
+// least version major.minor of the HTTP protocol.
+func ProtoAtLeast(major, minor int) MatcherFunc {
+	return func(r *http.Request) bool {
+		return r.ProtoAtLeast(major, minor)
+	}
+}
+
+// HasCookie returns a MatcherFunc which requires requests to carry a cookie
+// with the given name, whatever its value. Combined with a route for the
+// same path without the requirement, this makes it possible to serve
+// different variants to, say, logged-in and anonymous users.
+func HasCookie(name string) MatcherFunc {
+	return func(r *http.Request) bool {
+		_, err := r.Cookie(name)
+		return err == nil
+	}
+}
+
+// now returns the current time, and is replaced in tests.
+var now = time.Now
+
+// Between returns a MatcherFunc which only accepts requests arriving at or
+// after start, and before end, so routes for things like maintenance pages
+// or time-limited features activate and deactivate on their own. A zero
+// start or end leaves that side of the window open.
+func Between(start, end time.Time) MatcherFunc {
+	return func(r *http.Request) bool {
+		t := now()
+		return (start.IsZero() || !t.Before(start)) && (end.IsZero() || t.Before(end))
+	}
+}
+
+// wrap inserts a handler ahead of the route's existing handlers.
+func (r *Route) wrap(h Handler) *Route {
+	r.handlers = append([]Handler{h}, r.handlers...)
+	return r.publish()
+}
+
+// publish replaces the copy of the route in its Mux's table with a copy of
+// its current state. Routes which have since been discarded by Reset stay
+// discarded.
+func (r *Route) publish() *Route {
+	if r.mux == nil {
+		return r
+	}
+
+	live := r.clone()
+	r.mux.update(func(t *table) {
+		for i, rr := range t.routes {
+			if rr == r.live {
+				t.routes[i] = live
+			}
+		}
+	})
+
+	r.live = live
+	return r
+}
+
+// clone returns a copy of the route which shares none of its mutable state.
+func (r *Route) clone() *Route {
+	c := *r
+	c.handlers = append([]Handler(nil), r.handlers...)
+	c.conds = append([]condition(nil), r.conds...)
+	c.mux, c.live = nil, nil
+
+	if r.doc != nil {
+		d := *r.doc
+		if r.doc.responses != nil {
+			d.responses = make(map[int]interface{}, len(r.doc.responses))
+			for k, v := range r.doc.responses {
+				d.responses[k] = v
+			}
+		}
+		c.doc = &d
+	}
+
+	return &c
+}
+
+// allows reports whether the route is registered for a method.
+func (r *Route) allows(method string) bool {
+	for _, m := range r.methods {
+		if m == method {
+			return true
+		}
 	}
+	return false
+}
 
+// match tests whether the route's path and conditions match a request,
+// ignoring its method. Captured parameters are returned as a list of
+// name/value pairs.
+func (r *Route) match(hr *http.Request, path string) (bool, []string) {
 	ok, list := r.matcher.match(path, nil)
 	if !ok {
 		return false, nil
 	}
 
+	for _, c := range r.conds {
+		if ok, list = c(hr, list); !ok {
+			return false, nil
+		}
+	}
+
+	return true, list
+}
+
+// check tests whether the route matches a provided method and path. The
+// parameter map will always be non-nil when the first is true. The list of
+// parameter names holds them in the order they were captured.
+func (r *Route) check(hr *http.Request, path string) (bool, map[string]string, []string) {
+	if r.methods != nil && !r.allows(hr.Method) {
+		return false, nil, nil
+	}
+
+	ok, list := r.match(hr, path)
+	if !ok {
+		return false, nil, nil
+	}
+
 	// don't build the actual parameter map unless we have to
 	if len(list) == 0 {
-		return true, emptyParams
+		return true, emptyParams, nil
 	}
 
 	params := make(map[string]string)
+	keys := make([]string, 0, len(list)/2)
 	for i := 0; i < len(list); i += 2 {
+		if _, dup := params[list[i]]; !dup {
+			keys = append(keys, list[i])
+		}
 		params[list[i]] = list[i+1]
 	}
 
-	return true, params
+	return true, params, keys
+}
+
+// canToggleSlash reports whether a route in t would match the request if
+// its path's trailing slash was added or removed.
+func (m *Mux) canToggleSlash(t *table, hr *http.Request, path string) bool {
+	if t.strictSlash || path == "/" {
+		return false
+	}
+
+	path = toggleSlash(path)
+	for _, r := range t.routes {
+		if ok, _, _ := r.check(hr, path); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// toggleSlash adds a trailing slash to a path, or removes it if present.
+func toggleSlash(path string) string {
+	if n := len(path); n > 1 && path[n-1] == '/' {
+		return path[:n-1]
+	}
+	return path + "/"
 }
 
 // The queue type holds the routing state of an incoming request.
 type queue struct {
-	// remaining handlers, and parameter map, for the current route
+	// the current route, its remaining handlers, parameter map (and its
+	// keys, in pattern order) and total number of handlers
+	current  *Route
 	handlers []Handler
 	params   map[string]string
+	keys     []string
+	chain    int
+
+	// request-local data store, which points to local unless shared with
+	// a parent Mux
+	store **map[string]interface{}
+	local *map[string]interface{}
+
+	// remaining routes to be tested, and the path to test them against
+	// (which is still percent-encoded if raw is set)
+	routes []*Route
+	path   string
+	raw    bool
+
+	// the Mux being served and its table, whether any route has matched
+	// the request (and which one matched first), and whether the failure
+	// handlers have been invoked
+	mux     *Mux
+	table   *table
+	matched bool
+	route   *Route
+	failed  bool
 
-	// request-local data store
-	store *map[string]interface{}
+	// the request passed to the Mux, if it was created by another Mux
+	parent *Request
+}
+
+// begin starts a new chain of handlers, for a route or (if r is nil) the
+// failure handlers.
+func (q *queue) begin(r *Route, handlers []Handler, params map[string]string, keys []string) {
+	q.current = r
+	q.handlers = handlers
+	q.params = params
+	q.keys = keys
+	q.chain = len(handlers)
+}
 
-	// remaining routes to be tested
-	routes []*route
+// request creates a Request for the handler most recently taken from the
+// queue.
+func (q *queue) request(hr *http.Request) *Request {
+	return &Request{hr, nil, q.params, q.keys, q.store, q, q.current, q.chain - len(q.handlers)}
 }
 
 // ServeNext attempts to serve an HTTP request using the next matching
@@ -191,7 +1603,13 @@ func (q *queue) serveNext(w ResponseWriter, hr *http.Request) {
 		h := q.handlers[0]
 		q.handlers = q.handlers[1:]
 
-		h.ServeRoboHTTP(w, &Request{hr, nil, q.params, &q.store, q})
+		r := q.request(hr)
+		if th := q.table.trace; th != nil {
+			th.handlerStart(r)
+			defer th.handlerEnd(r)
+		}
+
+		h.ServeRoboHTTP(w, r)
 		return
 	}
 
@@ -201,19 +1619,107 @@ func (q *queue) serveNext(w ResponseWriter, hr *http.Request) {
 		q.routes = q.routes[1:]
 
 		// does this route match the request at hand?
-		ok, params := r.check(hr.Method, hr.URL.Path)
+		ok, params, keys := r.check(hr, q.path)
+		if !ok && q.table.strictSlash {
+			ok, params, keys = r.check(hr, toggleSlash(q.path))
+		}
 		if !ok {
 			continue
 		}
 
-		q.handlers = r.handlers[1:]
-		q.params = params
+		if q.raw {
+			for k, v := range params {
+				if u, err := url.PathUnescape(v); err == nil {
+					params[k] = u
+				}
+			}
+		}
+
+		handlers := r.handlers
+
+		// the Mux's middleware runs ahead of the first matching route
+		if !q.matched {
+			q.matched = true
+			q.route = r
+
+			if mw := q.table.middleware; len(mw) > 0 {
+				handlers = append(mw[:len(mw):len(mw)], handlers...)
+			}
+		}
+
+		q.begin(r, handlers, params, keys)
+		if th := q.table.trace; th != nil && th.OnMatch != nil {
+			th.OnMatch(hr, r.pattern.String())
+		}
 
-		// invoke the route's first handler
-		r.handlers[0].ServeRoboHTTP(w, &Request{hr, nil, q.params, &q.store, q})
+		// invoke the first handler
+		q.serveNext(w, hr)
 		return
 	}
 
-	// when we run out of routes, send a 404 message
+	// when we run out of routes, hand the request over to the failure
+	// handlers (only once, in case of calls to Next)
+	if !q.failed {
+		q.failed = true
+
+		if code := q.table.redirectSlash; code != 0 && !q.matched && q.mux.canToggleSlash(q.table, hr, q.path) {
+			// make sure clients preserve the method and body
+			if hr.Method != "GET" && hr.Method != "HEAD" {
+				if code == 301 {
+					code = 308
+				} else {
+					code = 307
+				}
+			}
+
+			url := toggleSlash(hr.URL.EscapedPath())
+			if hr.URL.RawQuery != "" {
+				url += "?" + hr.URL.RawQuery
+			}
+
+			http.Redirect(w, hr, url, code)
+			return
+		}
+
+		// nested Muxes without failure handlers of their own defer to
+		// their parent
+		if q.parent != nil && len(q.table.fallback) == 0 && len(q.table.notFound) == 0 {
+			q.parent.Next(w)
+			return
+		}
+
+		f := &RoutingFailure{Status: 404}
+		if !q.matched && !q.table.hideMethodNotAllowed {
+			if f.Allowed = q.mux.allowed(q.table, hr, q.path); len(f.Allowed) > 0 {
+				f.Status = 405
+			}
+		}
+
+		if th := q.table.trace; th != nil && th.OnNotFound != nil {
+			th.OnNotFound(hr, f)
+		}
+
+		switch {
+		case len(q.table.fallback) > 0:
+			q.begin(nil, q.table.fallback, emptyParams, nil)
+
+			q.request(hr).Set(FailureKey, f)
+			q.serveNext(w, hr)
+			return
+
+		case f.Status == 405:
+			w.Header().Set("Allow", strings.Join(f.Allowed, ", "))
+			http.Error(w, "Method not allowed.\n", 405)
+			return
+
+		case len(q.table.notFound) > 0:
+			q.begin(nil, q.table.notFound, emptyParams, nil)
+
+			q.serveNext(w, hr)
+			return
+		}
+	}
+
+	// send a plain 404 message
 	http.Error(w, "Not found.\n", 404)
 }
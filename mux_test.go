@@ -0,0 +1,1627 @@
+package robo
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echo writes the pattern of the matching route to the response body.
+func echo(pattern string) HandlerFunc {
+	return func(w ResponseWriter, r *Request) {
+		w.Write([]byte(pattern))
+	}
+}
+
+// serve dispatches a request through a handler, returning the recorded
+// response.
+func serve(h http.Handler, method, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+	return w
+}
+
+// readRequest parses a raw HTTP request.
+func readRequest(t *testing.T, raw string) *http.Request {
+	hr, err := http.ReadRequest(bufio.NewReader(strings.NewReader(raw)))
+	if err != nil {
+		t.Fatalf("http.ReadRequest(%q): %v", raw, err)
+	}
+	return hr
+}
+
+var proxyModeTests = []struct {
+	proxy bool
+	raw   string
+	code  int
+	body  string
+	host  string
+}{
+	{false, "GET /foo HTTP/1.1\r\nHost: example.com\r\n\r\n", 200, "/foo", "example.com"},
+	{false, "GET http://example.com/foo HTTP/1.1\r\nHost: other.com\r\n\r\n", 200, "/foo", "example.com"},
+	{false, "GET http://example.com HTTP/1.1\r\n\r\n", 200, "/", "example.com"},
+	{true, "GET /foo HTTP/1.1\r\nHost: example.com\r\n\r\n", 200, "/foo", "example.com"},
+	{true, "GET http://example.com/foo HTTP/1.1\r\nHost: other.com\r\n\r\n", 200, "/foo", "example.com"},
+	{true, "GET http://example.com HTTP/1.1\r\n\r\n", 200, "/", "example.com"},
+}
+
+func TestProxyMode(t *testing.T) {
+	for _, test := range proxyModeTests {
+		var host string
+
+		mux := NewMux()
+		mux.ProxyMode(test.proxy)
+		mux.Any("*", func(w ResponseWriter, r *Request) {
+			host = r.Host
+			r.Next(w)
+		})
+		mux.Get("/", echo("/"))
+		mux.Get("/foo", echo("/foo"))
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, readRequest(t, test.raw))
+
+		if w.Code != test.code || w.Body.String() != test.body || host != test.host {
+			t.Errorf("ProxyMode(%v), %q:", test.proxy, test.raw)
+			t.Errorf("  got  %d %q (host %q)", w.Code, w.Body.String(), host)
+			t.Errorf("  want %d %q (host %q)", test.code, test.body, test.host)
+		}
+	}
+}
+
+func TestProxyModeBuiltRequest(t *testing.T) {
+	for _, proxy := range []bool{false, true} {
+		var host string
+
+		mux := NewMux()
+		mux.ProxyMode(proxy)
+		mux.Host("example.com").Get("/foo", echo("example"))
+		mux.Get("/foo", func(w ResponseWriter, r *Request) {
+			host = r.Host
+		})
+
+		hr := httptest.NewRequest("GET", "http://example.com/foo", nil)
+		hr.Host = "other.com"
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, hr)
+
+		switch {
+		case proxy && w.Body.String() != "example":
+			t.Errorf("ProxyMode(true): got %q, want %q", w.Body.String(), "example")
+		case !proxy && host != "other.com":
+			t.Errorf("ProxyMode(false): got host %q, want %q", host, "other.com")
+		}
+		if hr.Host != "other.com" {
+			t.Errorf("ProxyMode(%v): request's Host changed to %q", proxy, hr.Host)
+		}
+	}
+}
+
+func TestEmptyPath(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		mux := NewMux()
+		mux.StrictSlash(strict)
+		mux.RedirectSlash(301)
+		mux.Get("/", echo("/"))
+
+		hr := httptest.NewRequest("GET", "/", nil)
+		hr.URL.Path = ""
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, hr)
+
+		if w.Code != 200 || w.Body.String() != "/" {
+			t.Errorf("StrictSlash(%v), empty path: got %d %q, want 200 %q", strict, w.Code, w.Body.String(), "/")
+		}
+	}
+}
+
+var methodNotAllowedTests = []struct {
+	hide   bool
+	method string
+	path   string
+	code   int
+	allow  string
+	body   string
+}{
+	{false, "GET", "/foo", 200, "", "/foo"},
+	{false, "DELETE", "/foo", 405, "GET, PUT", "Method not allowed.\n\n"},
+	{false, "DELETE", "/bar", 404, "", "custom"},
+	{true, "GET", "/foo", 200, "", "/foo"},
+	{true, "DELETE", "/foo", 404, "", "custom"},
+	{true, "DELETE", "/bar", 404, "", "custom"},
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	for _, test := range methodNotAllowedTests {
+		mux := NewMux()
+		mux.HideMethodNotAllowed(test.hide)
+		mux.NotFound(func(w ResponseWriter, r *Request) {
+			w.WriteHeader(404)
+			w.Write([]byte("custom"))
+		})
+		mux.Get("/foo", echo("/foo"))
+		mux.Put("/foo", echo("/foo"))
+
+		w := serve(mux, test.method, test.path)
+		allow := w.Header().Get("Allow")
+
+		if w.Code != test.code || allow != test.allow || w.Body.String() != test.body {
+			t.Errorf("HideMethodNotAllowed(%v), %s %s:", test.hide, test.method, test.path)
+			t.Errorf("  got  %d %q (Allow %q)", w.Code, w.Body.String(), allow)
+			t.Errorf("  want %d %q (Allow %q)", test.code, test.body, test.allow)
+		}
+	}
+}
+
+func TestNotFoundNext(t *testing.T) {
+	mux := NewMux()
+	mux.NotFound(func(w ResponseWriter, r *Request) {
+		w.Header().Set("X-Seen", "yes")
+		r.Next(w)
+	})
+
+	w := serve(mux, "GET", "/foo")
+	if w.Code != 404 || w.Header().Get("X-Seen") != "yes" {
+		t.Errorf("NotFound handler calling Next:")
+		t.Errorf("  got  %d (X-Seen %q)", w.Code, w.Header().Get("X-Seen"))
+		t.Errorf("  want 404 (X-Seen \"yes\")")
+	}
+}
+
+var fallbackTests = []struct {
+	method  string
+	path    string
+	status  int
+	allowed []string
+}{
+	{"GET", "/bar", 404, nil},
+	{"POST", "/foo", 405, []string{"DELETE", "GET"}},
+}
+
+func TestFallback(t *testing.T) {
+	for _, test := range fallbackTests {
+		var f *RoutingFailure
+
+		mux := NewMux()
+		mux.Get("/foo", echo("/foo"))
+		mux.Delete("/foo", echo("/foo"))
+		mux.Fallback(func(w ResponseWriter, r *Request) {
+			f, _ = r.Get(FailureKey).(*RoutingFailure)
+			w.WriteHeader(f.Status)
+		})
+
+		w := serve(mux, test.method, test.path)
+		if f == nil || w.Code != test.status || f.Status != test.status ||
+			strings.Join(f.Allowed, ",") != strings.Join(test.allowed, ",") {
+			t.Errorf("Fallback, %s %s:", test.method, test.path)
+			t.Errorf("  got  %d %+v", w.Code, f)
+			t.Errorf("  want %d %v", test.status, test.allowed)
+		}
+	}
+}
+
+var tryAddTests = []struct {
+	method   string
+	pattern  string
+	handlers []interface{}
+	err      error
+}{
+	{"GET", "/{id}", []interface{}{echo("/{id}")}, nil},
+	{"GET", "/{id", []interface{}{echo("/{id")}, errMissingRBrace},
+	{"GET", "/*/foo", []interface{}{echo("/*/foo")}, errIllegalWildcard},
+	{"", "/", []interface{}{echo("/")}, errEmptyMethod},
+	{"GET", "/", nil, errNoHandlers},
+	{"GET", "/", []interface{}{"nope"}, errInvalidHandler},
+}
+
+func TestTryAdd(t *testing.T) {
+	for _, test := range tryAddTests {
+		mux := NewMux()
+
+		err := mux.TryAdd(test.method, test.pattern, test.handlers...)
+		if err != test.err || (err == nil) != (len(mux.snapshot().routes) == 1) {
+			t.Errorf("TryAdd(%q, %q, %v):", test.method, test.pattern, test.handlers)
+			t.Errorf("  got  %v (%d routes)", err, len(mux.snapshot().routes))
+			t.Errorf("  want %v", test.err)
+		}
+	}
+}
+
+func TestAddWithPriority(t *testing.T) {
+	mux := NewMux()
+	mux.Get("/foo", echo("/foo"))
+	mux.Get("/{x}", echo("/{x}"))
+	mux.AddWithPriority(1, "GET", "/*", echo("/* (1)"))
+	mux.AddWithPriority(1, "GET", "/foo", echo("/foo (1)"))
+	mux.AddWithPriority(-1, "GET", "/bar", echo("/bar (-1)"))
+
+	var tests = []struct {
+		path string
+		body string
+	}{
+		{"/foo", "/* (1)"},
+		{"/bar", "/* (1)"},
+	}
+
+	for _, test := range tests {
+		if w := serve(mux, "GET", test.path); w.Body.String() != test.body {
+			t.Errorf("GET %s:", test.path)
+			t.Errorf("  got  %q", w.Body.String())
+			t.Errorf("  want %q", test.body)
+		}
+	}
+
+	var order []string
+	for _, r := range mux.snapshot().routes {
+		order = append(order, fmt.Sprintf("%s:%d", r.pattern, r.priority))
+	}
+
+	want := "/*:1 /foo:1 /foo:0 /{x}:0 /bar:-1"
+	if got := strings.Join(order, " "); got != want {
+		t.Errorf("route order:")
+		t.Errorf("  got  %s", got)
+		t.Errorf("  want %s", want)
+	}
+}
+
+// trace returns a middleware handler appending name to *log.
+func trace(log *[]string, name string) HandlerFunc {
+	return func(w ResponseWriter, r *Request) {
+		*log = append(*log, name)
+		r.Next(w)
+	}
+}
+
+func TestMount(t *testing.T) {
+	var log []string
+
+	a := NewMux()
+	a.Use(trace(&log, "a"))
+	a.Get("/", echo("a /"))
+	a.Get("/x", echo("a /x"))
+
+	b := NewMux()
+	b.Use(trace(&log, "b1"), trace(&log, "b2"))
+	b.Get("/x", echo("b /x"))
+
+	mux := NewMux()
+	mux.Use(trace(&log, "root"))
+	mux.Mount("/a", a)
+	mux.Mount("/b/", b)
+	mux.Get("/b/y", echo("/b/y"))
+
+	var tests = []struct {
+		path string
+		code int
+		body string
+		log  string
+	}{
+		{"/a", 200, "a /", "root a"},
+		{"/a/x", 200, "a /x", "root a"},
+		{"/b/x", 200, "b /x", "root b1 b2"},
+		{"/b/y", 200, "/b/y", "root"},
+		{"/ax", 404, "Not found.\n\n", ""},
+		{"/c", 404, "Not found.\n\n", ""},
+	}
+
+	for _, test := range tests {
+		log = nil
+
+		w := serve(mux, "GET", test.path)
+		if w.Code != test.code || w.Body.String() != test.body || strings.Join(log, " ") != test.log {
+			t.Errorf("GET %s:", test.path)
+			t.Errorf("  got  %d %q [%s]", w.Code, w.Body.String(), strings.Join(log, " "))
+			t.Errorf("  want %d %q [%s]", test.code, test.body, test.log)
+		}
+	}
+}
+
+func TestMountHTTP(t *testing.T) {
+	var log []string
+
+	sm := http.NewServeMux()
+	sm.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "legacy %s", r.URL.Path)
+	})
+
+	mux := NewMux()
+	mux.Use(trace(&log, "use"))
+	mux.MountHTTP("/legacy/", sm)
+	mux.Get("/*", echo("/*"))
+
+	var tests = []struct {
+		path string
+		body string
+		log  string
+	}{
+		{"/legacy", "legacy /", "use"},
+		{"/legacy/", "legacy /", "use"},
+		{"/legacy/a/b", "legacy /a/b", "use"},
+		{"/legacyx", "/*", "use"},
+		{"/other", "/*", "use"},
+	}
+
+	for _, test := range tests {
+		log = nil
+		w := serve(mux, "GET", test.path)
+		if w.Body.String() != test.body || strings.Join(log, " ") != test.log {
+			t.Errorf("GET %s:", test.path)
+			t.Errorf("  got  %q [%s]", w.Body.String(), strings.Join(log, " "))
+			t.Errorf("  want %q [%s]", test.body, test.log)
+		}
+	}
+}
+
+func TestNestedMountHTTP(t *testing.T) {
+	legacy := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s", r.URL.Path, r.URL.EscapedPath())
+	})
+
+	mux := NewMux()
+	child := NewMux()
+	child.MountHTTP("/legacy", legacy)
+	mux.Mount("/api", child)
+	mux.Version("v1", "v1", "v2").MountHTTP("/legacy", legacy)
+
+	raw := NewMux()
+	raw.DecodeBeforeMatch(false)
+	rawChild := NewMux()
+	rawChild.MountHTTP("/legacy", legacy)
+	raw.Mount("/api", rawChild)
+
+	var tests = []struct {
+		mux  *Mux
+		path string
+		body string
+	}{
+		{mux, "/api/legacy/x", "/x /x"},
+		{mux, "/api/legacy", "/ /"},
+		{mux, "/api/legacy/a%2Fb", "/a/b /a%2Fb"},
+		{mux, "/v2/legacy/x", "/x /x"},
+		{mux, "/legacy/x", "/x /x"},
+		{raw, "/api/legacy/a%2Fb", "/a/b /a%2Fb"},
+	}
+
+	for _, test := range tests {
+		if w := serve(test.mux, "GET", test.path); w.Body.String() != test.body {
+			t.Errorf("GET %s: got %q, want %q", test.path, w.Body.String(), test.body)
+		}
+	}
+}
+
+func TestNewChild(t *testing.T) {
+	mux := NewMux()
+	mux.StrictSlash(true)
+	mux.HideMethodNotAllowed(true)
+
+	inherit := mux.NewChild()
+	inherit.Get("/x", echo("inherit /x"))
+	mux.Mount("/inherit", inherit)
+
+	override := mux.NewChild()
+	override.StrictSlash(false)
+	override.Get("/x", echo("override /x"))
+	mux.Mount("/override", override)
+
+	// changes to the parent's options don't affect existing children
+	mux.HideMethodNotAllowed(false)
+
+	var tests = []struct {
+		method string
+		path   string
+		code   int
+		body   string
+	}{
+		{"GET", "/inherit/x/", 200, "inherit /x"},
+		{"POST", "/inherit/x", 404, "Not found.\n\n"},
+		{"GET", "/override/x", 200, "override /x"},
+		{"GET", "/override/x/", 404, "Not found.\n\n"},
+		{"POST", "/override/x", 404, "Not found.\n\n"},
+	}
+
+	for _, test := range tests {
+		w := serve(mux, test.method, test.path)
+		if w.Code != test.code || w.Body.String() != test.body {
+			t.Errorf("%s %s:", test.method, test.path)
+			t.Errorf("  got  %d %q", w.Code, w.Body.String())
+			t.Errorf("  want %d %q", test.code, test.body)
+		}
+	}
+
+	if host := mux.Host("example.com"); !host.snapshot().strictSlash {
+		t.Errorf("Host child doesn't inherit StrictSlash")
+	}
+}
+
+func TestMountTwice(t *testing.T) {
+	child := NewMux()
+	child.Get("/x/*", func(w ResponseWriter, r *Request) {
+		fmt.Fprintf(w, "%s %s", SpanName(r), RemainingPath(r))
+	})
+
+	mux := NewMux()
+	mux.Mount("/a", child)
+	mux.Mount("/b", child)
+	mux.Host("admin.example.com").Mount("/c", child)
+
+	var tests = []struct {
+		target string
+		code   int
+		body   string
+	}{
+		{"/a/x/1", 200, "GET /a/x/* 1"},
+		{"/b/x/2/3", 200, "GET /b/x/* 2/3"},
+		{"http://admin.example.com/c/x/", 200, "GET /c/x/* "},
+		{"/c/x/4", 404, "Not found.\n\n"},
+		{"/a/y", 404, "Not found.\n\n"},
+	}
+
+	for _, test := range tests {
+		w := serve(mux, "GET", test.target)
+		if w.Code != test.code || w.Body.String() != test.body {
+			t.Errorf("GET %s:", test.target)
+			t.Errorf("  got  %d %q", w.Code, w.Body.String())
+			t.Errorf("  want %d %q", test.code, test.body)
+		}
+	}
+}
+
+var strictSlashTests = []struct {
+	strict bool
+	method string
+	path   string
+	code   int
+	body   string
+}{
+	{false, "GET", "/users", 200, "/users"},
+	{false, "GET", "/users/", 404, "Not found.\n\n"},
+	{false, "GET", "/files/", 200, "/files/"},
+	{false, "GET", "/files", 404, "Not found.\n\n"},
+	{true, "GET", "/users", 200, "/users"},
+	{true, "GET", "/users/", 200, "/users"},
+	{true, "GET", "/files/", 200, "/files/"},
+	{true, "GET", "/files", 200, "/files/"},
+	{true, "POST", "/users/", 405, "Method not allowed.\n\n"},
+	{true, "GET", "/", 200, "/"},
+}
+
+func TestStrictSlash(t *testing.T) {
+	for _, test := range strictSlashTests {
+		mux := NewMux()
+		mux.StrictSlash(test.strict)
+		mux.Get("/", echo("/"))
+		mux.Get("/users", echo("/users"))
+		mux.Get("/files/", echo("/files/"))
+
+		w := serve(mux, test.method, test.path)
+		if w.Code != test.code || w.Body.String() != test.body {
+			t.Errorf("StrictSlash(%v), %s %s:", test.strict, test.method, test.path)
+			t.Errorf("  got  %d %q", w.Code, w.Body.String())
+			t.Errorf("  want %d %q", test.code, test.body)
+		}
+	}
+}
+
+var redirectSlashTests = []struct {
+	code     int
+	method   string
+	path     string
+	status   int
+	location string
+}{
+	{301, "GET", "/users/", 301, "/users"},
+	{301, "HEAD", "/files?a=b", 301, "/files/?a=b"},
+	{301, "POST", "/users/", 308, "/users"},
+	{308, "POST", "/users/", 308, "/users"},
+	{302, "GET", "/users/", 302, "/users"},
+	{302, "PUT", "/files", 307, "/files/"},
+	{301, "GET", "/users", 200, ""},
+	{301, "DELETE", "/users/", 404, ""},
+	{0, "GET", "/users/", 404, ""},
+}
+
+func TestRedirectSlash(t *testing.T) {
+	for _, test := range redirectSlashTests {
+		mux := NewMux()
+		mux.RedirectSlash(test.code)
+		mux.Add("GET|HEAD|POST", "/users", echo("/users"))
+		mux.Add("GET|HEAD|PUT", "/files/", echo("/files/"))
+
+		w := serve(mux, test.method, test.path)
+		if w.Code != test.status || w.Header().Get("Location") != test.location {
+			t.Errorf("RedirectSlash(%d), %s %s:", test.code, test.method, test.path)
+			t.Errorf("  got  %d %q", w.Code, w.Header().Get("Location"))
+			t.Errorf("  want %d %q", test.status, test.location)
+		}
+	}
+}
+
+func TestFromHTTP(t *testing.T) {
+	var after bool
+
+	h := FromHTTP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Path", r.URL.Path)
+		w.WriteHeader(404)
+	}))
+
+	mux := NewMux()
+	mux.NotFound(func(w ResponseWriter, r *Request) {
+		r.Next(w)
+		after = true
+	}, h)
+	mux.Get("/foo", h)
+
+	for _, path := range []string{"/foo", "/bar"} {
+		after = false
+
+		w := serve(mux, "GET", path)
+		if w.Code != 404 || w.Header().Get("X-Path") != path || after != (path == "/bar") {
+			t.Errorf("GET %s:", path)
+			t.Errorf("  got  %d (X-Path %q, after %v)", w.Code, w.Header().Get("X-Path"), after)
+			t.Errorf("  want 404 (X-Path %q, after %v)", path, path == "/bar")
+		}
+	}
+}
+
+func TestAddIf(t *testing.T) {
+	mux := NewMux()
+	mux.AddIf(true, "GET", "/debug", echo("/debug")).
+		AddIf(false, "GET", "/admin", echo("/admin"))
+
+	if w := serve(mux, "GET", "/debug"); w.Code != 200 {
+		t.Errorf("GET /debug: got %d, want 200", w.Code)
+	}
+	if w := serve(mux, "GET", "/admin"); w.Code != 404 {
+		t.Errorf("GET /admin: got %d, want 404", w.Code)
+	}
+}
+
+func TestReset(t *testing.T) {
+	mux := NewMux()
+	mux.StrictSlash(true)
+	mux.Use(trace(new([]string), "mw"))
+	mux.Get("/foo", echo("/foo"))
+
+	if w := serve(mux, "GET", "/foo"); w.Code != 200 {
+		t.Fatalf("GET /foo before Reset: got %d, want 200", w.Code)
+	}
+
+	mux.Reset()
+
+	if w := serve(mux, "GET", "/foo"); w.Code != 404 {
+		t.Errorf("GET /foo after Reset: got %d, want 404", w.Code)
+	}
+
+	mux.Get("/bar", echo("/bar"))
+
+	if w := serve(mux, "GET", "/bar"); w.Code != 200 || w.Body.String() != "/bar" {
+		t.Errorf("GET /bar after Reset: got %d %q, want 200 %q", w.Code, w.Body.String(), "/bar")
+	}
+
+	// options are reset as well
+	if w := serve(mux, "GET", "/bar/"); w.Code != 404 {
+		t.Errorf("GET /bar/ after Reset: got %d, want 404", w.Code)
+	}
+}
+
+func TestConcurrentRegistration(t *testing.T) {
+	mux := NewMux()
+	done := make(chan bool)
+
+	go func() {
+		for i := 0; i < 100; i++ {
+			mux.Get(fmt.Sprintf("/%d", i), echo("/"))
+			mux.StrictSlash(i%2 == 0)
+			if i%10 == 0 {
+				mux.Reset()
+			}
+		}
+		close(done)
+	}()
+
+	for {
+		select {
+		case <-done:
+			return
+		default:
+			serve(mux, "GET", "/5")
+		}
+	}
+}
+
+func TestConcurrentRouteModification(t *testing.T) {
+	mux := NewMux()
+	r := mux.Get("/foo", echo("/foo"))
+	done := make(chan bool)
+
+	go func() {
+		for i := 0; i < 100; i++ {
+			r.Value("i", i).Summary(fmt.Sprint(i)).Match(func(hr *http.Request) bool {
+				return true
+			})
+		}
+		close(done)
+	}()
+
+	for {
+		select {
+		case <-done:
+			r.Match(func(hr *http.Request) bool {
+				return false
+			})
+			if w := serve(mux, "GET", "/foo"); w.Code != 404 {
+				t.Errorf("GET /foo: got %d, want 404", w.Code)
+			}
+			return
+		default:
+			if w := serve(mux, "GET", "/foo"); w.Code != 200 || w.Body.String() != "/foo" {
+				t.Fatalf("GET /foo: got %d %q, want 200 %q", w.Code, w.Body.String(), "/foo")
+			}
+		}
+	}
+}
+
+var hostTests = []struct {
+	host string
+	path string
+	code int
+	body string
+}{
+	{"api.example.com", "/users", 200, "api /users"},
+	{"API.example.com:8080", "/users", 200, "api /users"},
+	{"api.example.com", "/missing", 404, "api 404"},
+	{"acme.example.com", "/", 200, "tenant acme"},
+	{"acme.example.com", "/missing", 404, "global 404"},
+	{"other.com", "/users", 404, "global 404"},
+}
+
+func TestHost(t *testing.T) {
+	mux := NewMux()
+	mux.NotFound(func(w ResponseWriter, r *Request) {
+		w.WriteHeader(404)
+		w.Write([]byte("global 404"))
+	})
+
+	api := mux.Host("api.example.com")
+	api.Get("/users", echo("api /users"))
+	api.NotFound(func(w ResponseWriter, r *Request) {
+		w.WriteHeader(404)
+		w.Write([]byte("api 404"))
+	})
+
+	tenant := mux.Host("{tenant}.example.com")
+	tenant.Get("/", func(w ResponseWriter, r *Request) {
+		w.Write([]byte("tenant " + r.Param("tenant")))
+	})
+
+	for _, test := range hostTests {
+		hr := httptest.NewRequest("GET", test.path, nil)
+		hr.Host = test.host
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, hr)
+
+		if w.Code != test.code || w.Body.String() != test.body {
+			t.Errorf("GET %s (Host %s):", test.path, test.host)
+			t.Errorf("  got  %d %q", w.Code, w.Body.String())
+			t.Errorf("  want %d %q", test.code, test.body)
+		}
+	}
+}
+
+var schemeTests = []struct {
+	tls    bool
+	remote string
+	proto  string
+	body   string
+}{
+	{true, "192.0.2.1:1234", "", "https"},
+	{false, "192.0.2.1:1234", "", "fallthrough"},
+	{false, "192.0.2.1:1234", "https", "fallthrough"},
+	{false, "10.1.2.3:1234", "https", "https"},
+	{false, "10.1.2.3:1234", "http", "fallthrough"},
+}
+
+func TestScheme(t *testing.T) {
+	mux := NewMux()
+	mux.TrustProxies("10.0.0.0/8")
+	mux.Scheme("https").Get("/", echo("https"))
+	mux.Get("/", echo("fallthrough"))
+
+	for _, test := range schemeTests {
+		hr := httptest.NewRequest("GET", "/", nil)
+		hr.RemoteAddr = test.remote
+		if test.tls {
+			hr.TLS = &tls.ConnectionState{}
+		}
+		if test.proto != "" {
+			hr.Header.Set("X-Forwarded-Proto", test.proto)
+		}
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, hr)
+
+		if w.Body.String() != test.body {
+			t.Errorf("GET / (tls %v, from %s, proto %q):", test.tls, test.remote, test.proto)
+			t.Errorf("  got  %q", w.Body.String())
+			t.Errorf("  want %q", test.body)
+		}
+	}
+}
+
+func TestHeader(t *testing.T) {
+	mux := NewMux()
+	mux.Header("X-Tenant", "tenant").Get("/", func(w ResponseWriter, r *Request) {
+		w.Write([]byte("tenant " + r.Param("tenant")))
+	})
+	mux.Get("/", echo("fallthrough"))
+
+	var tests = []struct {
+		tenant string
+		body   string
+	}{
+		{"acme", "tenant acme"},
+		{"", "fallthrough"},
+	}
+
+	for _, test := range tests {
+		hr := httptest.NewRequest("GET", "/", nil)
+		if test.tenant != "" {
+			hr.Header.Set("x-tenant", test.tenant)
+		}
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, hr)
+
+		if w.Body.String() != test.body {
+			t.Errorf("GET / (X-Tenant %q):", test.tenant)
+			t.Errorf("  got  %q", w.Body.String())
+			t.Errorf("  want %q", test.body)
+		}
+	}
+}
+
+func TestVersion(t *testing.T) {
+	mux := NewMux()
+	api := mux.Version("v1", "v1", "v2")
+	api.Get("/users/{id}", func(w ResponseWriter, r *Request) {
+		w.Write([]byte(r.Param("version") + " " + r.Param("id")))
+	})
+	api.Get("/", echo("/"))
+	mux.Get("/other", echo("/other"))
+
+	var tests = []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/v2/users/5", 200, "v2 5"},
+		{"/v1/users/5", 200, "v1 5"},
+		{"/users/5", 200, "v1 5"},
+		{"/v2", 200, "/"},
+		{"/v3/users/5", 404, "Not found.\n\n"},
+		{"/other", 200, "/other"},
+	}
+
+	for _, test := range tests {
+		w := serve(mux, "GET", test.path)
+		if w.Code != test.code || w.Body.String() != test.body {
+			t.Errorf("GET %s:", test.path)
+			t.Errorf("  got  %d %q", w.Code, w.Body.String())
+			t.Errorf("  want %d %q", test.code, test.body)
+		}
+	}
+}
+
+func TestDecodeBeforeMatch(t *testing.T) {
+	var tests = []struct {
+		decode bool
+		path   string
+		body   string
+	}{
+		{true, "/files/a%2Fb", "nested a"},
+		{false, "/files/a%2Fb", "file a/b"},
+		{true, "/files/a%20b", "file a b"},
+		{false, "/files/a%20b", "file a b"},
+		{false, "/files/a/b", "nested a"},
+	}
+
+	for _, test := range tests {
+		mux := NewMux()
+		mux.DecodeBeforeMatch(test.decode)
+		mux.Get("/files/{name}", func(w ResponseWriter, r *Request) {
+			w.Write([]byte("file " + r.Param("name")))
+		})
+		mux.Get("/files/{dir}/b", func(w ResponseWriter, r *Request) {
+			w.Write([]byte("nested " + r.Param("dir")))
+		})
+
+		w := serve(mux, "GET", test.path)
+		if w.Body.String() != test.body {
+			t.Errorf("GET %s (decode %v):", test.path, test.decode)
+			t.Errorf("  got  %q", w.Body.String())
+			t.Errorf("  want %q", test.body)
+		}
+	}
+}
+
+func TestAddPatterns(t *testing.T) {
+	mux := NewMux()
+	mux.AddPatterns("GET", []string{"/", "/index.html", "/{page}.html"}, func(w ResponseWriter, r *Request) {
+		w.Write([]byte("index " + r.Param("page")))
+	})
+
+	var tests = []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/", 200, "index "},
+		{"/index.html", 200, "index "},
+		{"/home.html", 200, "index home"},
+		{"/home", 404, "Not found.\n\n"},
+	}
+
+	for _, test := range tests {
+		w := serve(mux, "GET", test.path)
+		if w.Code != test.code || w.Body.String() != test.body {
+			t.Errorf("GET %s:", test.path)
+			t.Errorf("  got  %d %q", w.Code, w.Body.String())
+			t.Errorf("  want %d %q", test.code, test.body)
+		}
+	}
+
+	func() {
+		defer func() { recover() }()
+		mux.AddPatterns("GET", []string{"/a", "/{b"}, echo("/a"))
+	}()
+
+	if w := serve(mux, "GET", "/a"); w.Code != 404 {
+		t.Errorf("AddPatterns with an invalid pattern registered a route")
+	}
+}
+
+func TestExact(t *testing.T) {
+	mux := NewMux()
+	mux.Exact("GET", "/users/{id}", echo("exact"))
+	mux.Get("/users/{id}", echo("pattern"))
+
+	var tests = []struct {
+		path string
+		body string
+	}{
+		{"/users/{id}", "exact"},
+		{"/users/5", "pattern"},
+		{"/users/{id}/", "Not found.\n\n"},
+		{"/users/{id}/x", "Not found.\n\n"},
+	}
+
+	for _, test := range tests {
+		if w := serve(mux, "GET", test.path); w.Body.String() != test.body {
+			t.Errorf("GET %s:", test.path)
+			t.Errorf("  got  %q", w.Body.String())
+			t.Errorf("  want %q", test.body)
+		}
+	}
+}
+
+func benchmarkRegistration(b *testing.B, register func(m *Mux, path string)) {
+	for i := 0; i < b.N; i++ {
+		register(NewMux(), "/api/v1/users/profile")
+	}
+}
+
+func BenchmarkRegisterAdd(b *testing.B) {
+	benchmarkRegistration(b, func(m *Mux, path string) {
+		m.Add("GET", path, echo(path))
+	})
+}
+
+func BenchmarkRegisterExact(b *testing.B) {
+	benchmarkRegistration(b, func(m *Mux, path string) {
+		m.Exact("GET", path, echo(path))
+	})
+}
+
+func benchmarkStaticRoutes(b *testing.B, register func(m *Mux, path string)) {
+	mux := NewMux()
+	for i := 0; i < 50; i++ {
+		register(mux, fmt.Sprintf("/api/v1/resource%d", i))
+	}
+
+	w := httptest.NewRecorder()
+	hr := httptest.NewRequest("GET", "/api/v1/resource49", nil)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		mux.ServeHTTP(w, hr)
+	}
+}
+
+func BenchmarkServeAdd(b *testing.B) {
+	benchmarkStaticRoutes(b, func(m *Mux, path string) {
+		m.Add("GET", path, func(w ResponseWriter, r *Request) {})
+	})
+}
+
+func BenchmarkServeExact(b *testing.B) {
+	benchmarkStaticRoutes(b, func(m *Mux, path string) {
+		m.Exact("GET", path, func(w ResponseWriter, r *Request) {})
+	})
+}
+
+func TestAddMatcher(t *testing.T) {
+	mux := NewMux()
+	mux.AddMatcher(func(r *http.Request) (bool, map[string]string) {
+		if flag := r.Header.Get("X-Feature"); flag != "" {
+			return true, map[string]string{"feature": flag}
+		}
+		return false, nil
+	}, func(w ResponseWriter, r *Request) {
+		w.Write([]byte("feature " + r.Param("feature")))
+	})
+	mux.Get("/", echo("/"))
+
+	var tests = []struct {
+		path    string
+		feature string
+		body    string
+	}{
+		{"/", "", "/"},
+		{"/", "beta", "/"},
+		{"/other", "beta", "feature beta"},
+		{"/other", "", "Not found.\n\n"},
+	}
+
+	for _, test := range tests {
+		hr := httptest.NewRequest("GET", test.path, nil)
+		if test.feature != "" {
+			hr.Header.Set("X-Feature", test.feature)
+		}
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, hr)
+
+		if w.Body.String() != test.body {
+			t.Errorf("GET %s (X-Feature %q):", test.path, test.feature)
+			t.Errorf("  got  %q", w.Body.String())
+			t.Errorf("  want %q", test.body)
+		}
+	}
+}
+
+func TestMethodList(t *testing.T) {
+	mux := NewMux()
+	mux.Add("GET|POST|PUT", "/items", echo("/items"))
+
+	var tests = []struct {
+		method string
+		code   int
+		allow  string
+	}{
+		{"GET", 200, ""},
+		{"POST", 200, ""},
+		{"PUT", 200, ""},
+		{"DELETE", 405, "GET, POST, PUT"},
+	}
+
+	for _, test := range tests {
+		w := serve(mux, test.method, "/items")
+		if w.Code != test.code || w.Header().Get("Allow") != test.allow {
+			t.Errorf("%s /items:", test.method)
+			t.Errorf("  got  %d (Allow %q)", w.Code, w.Header().Get("Allow"))
+			t.Errorf("  want %d (Allow %q)", test.code, test.allow)
+		}
+	}
+
+	if err := mux.TryAdd("GET||POST", "/", echo("/")); err != errEmptyMethod {
+		t.Errorf("TryAdd(%q): got %v, want %v", "GET||POST", err, errEmptyMethod)
+	}
+}
+
+func TestOnPanic(t *testing.T) {
+	var recovered interface{}
+
+	custom := NewMux()
+	custom.OnPanic(func(w ResponseWriter, r *Request, v interface{}) {
+		recovered = v
+		w.WriteHeader(500)
+		w.Write([]byte("oops"))
+	})
+	custom.NotFound(func(w ResponseWriter, r *Request) {
+		panic("not found")
+	})
+	custom.Get("/", func(w ResponseWriter, r *Request) {
+		panic("handler")
+	})
+
+	plain := NewMux()
+	plain.OnPanic(nil)
+	plain.Get("/", func(w ResponseWriter, r *Request) {
+		panic("handler")
+	})
+
+	var tests = []struct {
+		mux       *Mux
+		path      string
+		recovered interface{}
+		body      string
+	}{
+		{custom, "/", "handler", "oops"},
+		{custom, "/missing", "not found", "oops"},
+		{plain, "/", nil, "Internal server error.\n\n"},
+	}
+
+	for _, test := range tests {
+		recovered = nil
+
+		w := serve(test.mux, "GET", test.path)
+		if w.Code != 500 || w.Body.String() != test.body || recovered != test.recovered {
+			t.Errorf("GET %s:", test.path)
+			t.Errorf("  got  %d %q (recovered %v)", w.Code, w.Body.String(), recovered)
+			t.Errorf("  want 500 %q (recovered %v)", test.body, test.recovered)
+		}
+	}
+}
+
+func TestRouteValue(t *testing.T) {
+	var got []interface{}
+	remember := func(w ResponseWriter, r *Request) {
+		got = append(got, r.Get("resource"))
+	}
+
+	mux := NewMux()
+	mux.Get("/users/{id}", remember).Value("resource", "user")
+	mux.Get("/posts/{id}", remember).Value("resource", "post")
+	mux.Get("/", remember)
+
+	for _, path := range []string{"/users/1", "/posts/2", "/"} {
+		serve(mux, "GET", path)
+	}
+
+	want := []interface{}{"user", "post", nil}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Value:")
+		t.Errorf("  got  %v", got)
+		t.Errorf("  want %v", want)
+	}
+}
+
+func TestNextFallthrough(t *testing.T) {
+	var log []string
+
+	mux := NewMux()
+	mux.Get("/{id}", trace(&log, "first"))
+	mux.Get("/{id}", trace(&log, "second"))
+	mux.Get("/other", trace(&log, "other"))
+	mux.Get("/x", func(w ResponseWriter, r *Request) {
+		log = append(log, "third")
+		w.Write([]byte("third"))
+	})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- serve(mux, "GET", "/x")
+	}()
+
+	select {
+	case w := <-done:
+		want := []string{"first", "second", "third"}
+		if fmt.Sprint(log) != fmt.Sprint(want) || w.Body.String() != "third" {
+			t.Errorf("GET /x:")
+			t.Errorf("  got  %v %q", log, w.Body.String())
+			t.Errorf("  want %v %q", want, "third")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("GET /x: Next looped instead of passing the request on")
+	}
+}
+
+func TestDuplicateParameters(t *testing.T) {
+	mux := NewMux()
+
+	func() {
+		defer func() {
+			if v := recover(); v != errDuplicateParameter {
+				t.Errorf("Get(%q) panicked with %v, want %v", "/{id}/{id}", v, errDuplicateParameter)
+			}
+		}()
+		mux.Get("/{id}/{id}", echo("/{id}/{id}"))
+	}()
+
+	mux.Get("/{a}/{b}", echo("/{a}/{b}"))
+	if w := serve(mux, "GET", "/x/y"); w.Body.String() != "/{a}/{b}" {
+		t.Errorf("GET /x/y: got %q, want %q", w.Body.String(), "/{a}/{b}")
+	}
+}
+
+func TestHasRoute(t *testing.T) {
+	mux := NewMux()
+	mux.Get("/users/{id[0-9]}", echo("/users/{id}"))
+	mux.Add("POST|PUT", "/items/{name}.{ext}", echo("/items"))
+	mux.Any("/ping", echo("/ping"))
+
+	var tests = []struct {
+		method  string
+		pattern string
+		want    bool
+	}{
+		{"GET", "/users/{id[0-9]}", true},
+		{"GET", "/users/{uid[0-9]}", true},
+		{"GET", "/users/{id}", false},
+		{"POST", "/users/{id[0-9]}", false},
+		{"PUT|POST", "/items/{n}.{e}", true},
+		{"POST", "/items/{name}.{ext}", false},
+		{"", "/ping", true},
+		{"GET", "/ping", false},
+		{"GET", "/missing", false},
+		{"GET", "/{broken", false},
+	}
+
+	for _, test := range tests {
+		if got := mux.HasRoute(test.method, test.pattern); got != test.want {
+			t.Errorf("HasRoute(%q, %q) = %v, want %v", test.method, test.pattern, got, test.want)
+		}
+	}
+}
+
+func TestRouteValidate(t *testing.T) {
+	positive := func(v string) error {
+		if n, err := strconv.Atoi(v); err != nil || n <= 0 {
+			return errors.New("not a positive integer")
+		}
+		return nil
+	}
+
+	mux := NewMux()
+	mux.Get("/users/{id}", echo("/users/{id}")).Validate("id", positive)
+	mux.Get("/users/*", echo("/users/*"))
+	mux.Get("/items/{name}", echo("/items/{name}")).Validate("missing", positive)
+
+	var tests = []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/users/5", 200, "/users/{id}"},
+		{"/users/0", 400, "Bad request.\n\n"},
+		{"/users/abc", 400, "Bad request.\n\n"},
+		{"/items/x", 200, "/items/{name}"},
+	}
+
+	for _, test := range tests {
+		if w := serve(mux, "GET", test.path); w.Code != test.code || w.Body.String() != test.body {
+			t.Errorf("GET %s:", test.path)
+			t.Errorf("  got  %d %q", w.Code, w.Body.String())
+			t.Errorf("  want %d %q", test.code, test.body)
+		}
+	}
+}
+
+func TestDump(t *testing.T) {
+	api := NewMux()
+	api.Get("/users/{id}", echo("/users/{id}")).Summary("Get a user").Tags("users", "read")
+	api.Add("POST|PUT", "/users", trace(new([]string), "x"), echo("/users")).MaxBody(1024)
+
+	register := []func(m *Mux){
+		func(m *Mux) { m.Get("/", echo("/")) },
+		func(m *Mux) { m.Any("/ping", echo("/ping")) },
+		func(m *Mux) { m.AddWithPriority(5, "DELETE", "/", echo("/")) },
+		func(m *Mux) { m.Mount("/api", api) },
+		func(m *Mux) { m.Host("admin.example.com").Get("/", echo("admin")) },
+	}
+
+	// MaxBody adds a handler of its own
+	want := "DELETE / handlers=1 priority=5\n" +
+		"GET / handlers=1\n" +
+		"GET / handlers=1 conditions=1\n" +
+		"POST|PUT /api/users handlers=3 maxbody=1024\n" +
+		"GET /api/users/{id} handlers=1 summary=\"Get a user\" tags=users,read\n" +
+		"* /ping handlers=1\n"
+
+	forward, backward := NewMux(), NewMux()
+	for i := range register {
+		register[i](forward)
+		register[len(register)-1-i](backward)
+	}
+
+	if got := forward.Dump(); got != want {
+		t.Errorf("Dump:\ngot:\n%swant:\n%s", got, want)
+	}
+	if got := backward.Dump(); got != want {
+		t.Errorf("Dump (reverse registration order):\ngot:\n%swant:\n%s", got, want)
+	}
+}
+
+func authenticate(w ResponseWriter, r *Request) { r.Next(w) }
+func audit(w ResponseWriter, r *Request)        { r.Next(w) }
+
+type namedMiddleware struct{}
+
+func (namedMiddleware) ServeRoboHTTP(w ResponseWriter, r *Request) { r.Next(w) }
+
+func TestMiddlewareFor(t *testing.T) {
+	api := NewMux()
+	api.Use(authenticate, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	api.Get("/users/{id}", echo("/users/{id}"))
+
+	mux := NewMux()
+	mux.Always(namedMiddleware{})
+	mux.Use(audit)
+	mux.Mount("/api", api)
+	mux.Get("/", echo("/"))
+
+	// strip the package path, which depends on how the tests are built
+	short := func(names []string) []string {
+		for i, name := range names {
+			if n := strings.Index(name, "."); n >= 0 {
+				names[i] = name[n+1:]
+			}
+		}
+		return names
+	}
+
+	var tests = []struct {
+		method  string
+		pattern string
+		want    []string
+	}{
+		{"GET", "/", []string{"namedMiddleware", "audit"}},
+		{"GET", "/api/users/{uid}", []string{"namedMiddleware", "audit", "authenticate", "TestMiddlewareFor.func1"}},
+		{"POST", "/api/users/{id}", nil},
+		{"GET", "/missing", nil},
+	}
+
+	for _, test := range tests {
+		got := short(mux.MiddlewareFor(test.method, test.pattern))
+		if fmt.Sprint(got) != fmt.Sprint(test.want) || (got == nil) != (test.want == nil) {
+			t.Errorf("MiddlewareFor(%q, %q):", test.method, test.pattern)
+			t.Errorf("  got  %q", got)
+			t.Errorf("  want %q", test.want)
+		}
+	}
+}
+
+func TestAlways(t *testing.T) {
+	var log []string
+
+	mux := NewMux()
+	mux.Always(trace(&log, "always"))
+	mux.Use(trace(&log, "use"))
+	mux.Get("/", func(w ResponseWriter, r *Request) {
+		log = append(log, "route")
+	})
+
+	var tests = []struct {
+		method string
+		path   string
+		code   int
+		log    []string
+	}{
+		{"GET", "/", 200, []string{"always", "use", "route"}},
+		{"GET", "/missing", 404, []string{"always"}},
+		{"POST", "/", 405, []string{"always"}},
+	}
+
+	for _, test := range tests {
+		log = nil
+
+		w := serve(mux, test.method, test.path)
+		if w.Code != test.code || fmt.Sprint(log) != fmt.Sprint(test.log) {
+			t.Errorf("%s %s:", test.method, test.path)
+			t.Errorf("  got  %d %v", w.Code, log)
+			t.Errorf("  want %d %v", test.code, test.log)
+		}
+	}
+}
+
+func TestRouteProduces(t *testing.T) {
+	mux := NewMux()
+	mux.Get("/default", echo("/default")).Produces("application/json")
+	mux.Get("/override", func(w ResponseWriter, r *Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Write([]byte("a,b"))
+	}).Produces("application/json")
+	mux.Get("/middleware", func(w ResponseWriter, r *Request) {
+		w.Header().Set("Content-Type", "text/html")
+		r.Next(w)
+	}, echo("/middleware")).Produces("application/json")
+
+	var tests = []struct {
+		path  string
+		ctype string
+	}{
+		{"/default", "application/json"},
+		{"/override", "text/csv"},
+		{"/middleware", "text/html"},
+	}
+
+	for _, test := range tests {
+		w := serve(mux, "GET", test.path)
+		if ctype := w.Header().Get("Content-Type"); ctype != test.ctype {
+			t.Errorf("GET %s: Content-Type = %q, want %q", test.path, ctype, test.ctype)
+		}
+	}
+}
+
+func TestDefaultProduces(t *testing.T) {
+	mux := NewMux()
+	mux.DefaultProduces("application/json")
+	mux.Get("/plain", func(w ResponseWriter, r *Request) {
+		w.Write([]byte(`{"ok":true}`))
+	})
+	mux.Get("/html", func(w ResponseWriter, r *Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<p>hi</p>"))
+	})
+	mux.Get("/route", echo("/route")).Produces("text/csv")
+	mux.Get("/created", func(w ResponseWriter, r *Request) {
+		w.WriteHeader(201)
+	})
+	mux.Get("/empty", func(w ResponseWriter, r *Request) {
+		w.WriteHeader(204)
+	})
+
+	var tests = []struct {
+		path  string
+		ctype string
+	}{
+		{"/plain", "application/json"},
+		{"/html", "text/html"},
+		{"/route", "text/csv"},
+		{"/created", "application/json"},
+		{"/empty", ""},
+		{"/missing", "text/plain; charset=utf-8"},
+	}
+
+	for _, test := range tests {
+		if ctype := serve(mux, "GET", test.path).Header().Get("Content-Type"); ctype != test.ctype {
+			t.Errorf("GET %s: Content-Type = %q, want %q", test.path, ctype, test.ctype)
+		}
+	}
+}
+
+func TestSubrouter(t *testing.T) {
+	sub := NewMux()
+	sub.Get("/repos/{repo}", func(w ResponseWriter, r *Request) {
+		fmt.Fprintf(w, "%s/%s at %s", r.Param("org"), r.Param("repo"), r.URL.Path)
+	})
+	sub.Get("/", func(w ResponseWriter, r *Request) {
+		fmt.Fprintf(w, "%s at %s", r.Param("org"), r.URL.Path)
+	})
+
+	mux := NewMux()
+	mux.Get("/orgs/{org}/*", Subrouter(sub))
+	mux.Get("/orgs/{org}/members", echo("members"))
+
+	var tests = []struct {
+		path string
+		body string
+	}{
+		{"/orgs/acme/repos/robo", "acme/robo at /repos/robo"},
+		{"/orgs/acme/", "acme at /"},
+		{"/orgs/acme/members", "members"},
+	}
+
+	for _, test := range tests {
+		w := serve(mux, "GET", test.path)
+		if w.Body.String() != test.body {
+			t.Errorf("GET %s:", test.path)
+			t.Errorf("  got  %q", w.Body.String())
+			t.Errorf("  want %q", test.body)
+		}
+	}
+}
+
+func TestSubrouterRawPath(t *testing.T) {
+	sub := NewMux()
+	sub.Get("/{name}", func(w ResponseWriter, r *Request) {
+		fmt.Fprint(w, r.Param("name"))
+	})
+
+	mux := NewMux()
+	mux.DecodeBeforeMatch(false)
+	mux.Get("/t/*", Subrouter(sub))
+
+	if w := serve(mux, "GET", "/t/a%2541"); w.Body.String() != "a%41" {
+		t.Errorf("GET /t/a%%2541: got %q, want %q", w.Body.String(), "a%41")
+	}
+}
+
+func TestRouteParams(t *testing.T) {
+	mux := NewMux()
+
+	var tests = []struct {
+		route  *Route
+		params []string
+	}{
+		{mux.Get("/orgs/{org}/repos/{repo[a-z]}/{file}.{ext}", echo("")), []string{"org", "repo", "file", "ext"}},
+		{mux.Get("/files/*", echo("")), []string{}},
+		{mux.Get("/about", echo("")), []string{}},
+	}
+
+	for _, test := range tests {
+		params := test.route.Params()
+		if params == nil || fmt.Sprint(params) != fmt.Sprint(test.params) {
+			t.Errorf("Params() for %q = %#v, want %#v", test.route.pattern, params, test.params)
+		}
+	}
+}
+
+func TestProtoAtLeast(t *testing.T) {
+	mux := NewMux()
+	mux.Get("/", echo("http/2")).Match(ProtoAtLeast(2, 0))
+	mux.Get("/", echo("fallthrough"))
+
+	var tests = []struct {
+		major, minor int
+		body         string
+	}{
+		{2, 0, "http/2"},
+		{3, 0, "http/2"},
+		{1, 1, "fallthrough"},
+		{1, 0, "fallthrough"},
+	}
+
+	for _, test := range tests {
+		hr := httptest.NewRequest("GET", "/", nil)
+		hr.ProtoMajor, hr.ProtoMinor = test.major, test.minor
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, hr)
+
+		if w.Body.String() != test.body {
+			t.Errorf("GET / (HTTP/%d.%d): got %q, want %q", test.major, test.minor, w.Body.String(), test.body)
+		}
+	}
+}
+
+func TestHasCookie(t *testing.T) {
+	mux := NewMux()
+	mux.Get("/", echo("session")).Match(HasCookie("session"))
+	mux.Get("/", echo("anonymous"))
+
+	var tests = []struct {
+		cookie string
+		body   string
+	}{
+		{"session=abc", "session"},
+		{"theme=dark; session=", "session"},
+		{"theme=dark", "anonymous"},
+		{"", "anonymous"},
+	}
+
+	for _, test := range tests {
+		hr := httptest.NewRequest("GET", "/", nil)
+		if test.cookie != "" {
+			hr.Header.Set("Cookie", test.cookie)
+		}
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, hr)
+
+		if w.Body.String() != test.body {
+			t.Errorf("GET / (Cookie %q): got %q, want %q", test.cookie, w.Body.String(), test.body)
+		}
+	}
+}
+
+func TestBetween(t *testing.T) {
+	start := time.Date(2024, 3, 1, 2, 0, 0, 0, time.UTC)
+	end := start.Add(2 * time.Hour)
+
+	var clock time.Time
+	defer func(fn func() time.Time) { now = fn }(now)
+	now = func() time.Time { return clock }
+
+	mux := NewMux()
+	mux.Get("/", echo("maintenance")).Match(Between(start, end))
+	mux.Get("/", echo("normal"))
+	mux.Get("/new", echo("launched")).Match(Between(end, time.Time{}))
+	mux.Get("/new", echo("not yet"))
+
+	var tests = []struct {
+		clock time.Time
+		path  string
+		body  string
+	}{
+		{start.Add(-time.Second), "/", "normal"},
+		{start, "/", "maintenance"},
+		{start.Add(time.Hour), "/", "maintenance"},
+		{end, "/", "normal"},
+		{start, "/new", "not yet"},
+		{end, "/new", "launched"},
+		{end.AddDate(1, 0, 0), "/new", "launched"},
+	}
+
+	for _, test := range tests {
+		clock = test.clock
+		if w := serve(mux, "GET", test.path); w.Body.String() != test.body {
+			t.Errorf("GET %s at %s: got %q, want %q", test.path, test.clock.Format(time.RFC3339), w.Body.String(), test.body)
+		}
+	}
+}
+
+func TestOnPanicStatusCoder(t *testing.T) {
+	mux := NewMux()
+	mux.OnPanic(nil)
+	mux.Get("/error", func(w ResponseWriter, r *Request) {
+		panic(NewError(404, "No such user."))
+	})
+	mux.Get("/string", func(w ResponseWriter, r *Request) {
+		panic("oops")
+	})
+
+	var tests = []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/error", 404, "No such user.\n\n"},
+		{"/string", 500, "Internal server error.\n\n"},
+	}
+
+	for _, test := range tests {
+		w := serve(mux, "GET", test.path)
+		if w.Code != test.code || w.Body.String() != test.body {
+			t.Errorf("GET %s:", test.path)
+			t.Errorf("  got  %d %q", w.Code, w.Body.String())
+			t.Errorf("  want %d %q", test.code, test.body)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	var log []string
+
+	mux := NewMux()
+	mux.Filter(func(r *http.Request) bool {
+		log = append(log, "length")
+		return len(r.URL.Path) <= 10
+	})
+	mux.Filter(func(r *http.Request) bool {
+		log = append(log, "dots")
+		return !strings.Contains(r.URL.Path, "..")
+	})
+	mux.Add("GET", "*", func(w ResponseWriter, r *Request) {
+		log = append(log, "route")
+		w.Write([]byte("route"))
+	})
+	mux.NotFound(func(w ResponseWriter, r *Request) {
+		w.WriteHeader(404)
+		w.Write([]byte("rejected"))
+	})
+
+	var tests = []struct {
+		method string
+		path   string
+		code   int
+		body   string
+		log    []string
+	}{
+		{"GET", "/short", 200, "route", []string{"length", "dots", "route"}},
+		{"GET", "/much/too/long", 404, "rejected", []string{"length"}},
+		{"GET", "/a/../b", 404, "rejected", []string{"length", "dots"}},
+		{"POST", "/much/too/long", 404, "rejected", []string{"length"}},
+	}
+
+	for _, test := range tests {
+		log = nil
+
+		w := serve(mux, test.method, test.path)
+		if w.Code != test.code || w.Body.String() != test.body || fmt.Sprint(log) != fmt.Sprint(test.log) {
+			t.Errorf("%s %s:", test.method, test.path)
+			t.Errorf("  got  %d %q %v", w.Code, w.Body.String(), log)
+			t.Errorf("  want %d %q %v", test.code, test.body, test.log)
+		}
+	}
+}
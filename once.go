@@ -0,0 +1,34 @@
+package robo
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// Once returns a handler which calls fn before the first request reaches
+// h, for lazily setting up resources h depends on. Concurrent requests
+// wait for fn to return. If fn fails, the request receives a plain 500
+// response, and fn is called again for the next request, until it
+// succeeds; after that h is invoked directly.
+func Once(fn func() error, h Handler) Handler {
+	var mu sync.Mutex
+	var done atomic.Bool
+
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		if !done.Load() {
+			mu.Lock()
+			if !done.Load() {
+				if err := fn(); err != nil {
+					mu.Unlock()
+					http.Error(w, "Internal server error.\n", 500)
+					return
+				}
+				done.Store(true)
+			}
+			mu.Unlock()
+		}
+
+		h.ServeRoboHTTP(w, r)
+	})
+}
@@ -0,0 +1,83 @@
+package robo
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOnce(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan bool)
+
+	mux := NewMux()
+	mux.Get("/", Once(func() error {
+		calls.Add(1)
+		<-release
+		return nil
+	}, echo("/")))
+
+	var wg sync.WaitGroup
+	codes := make(chan int, 10)
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- serve(mux, "GET", "/").Code
+		}()
+	}
+
+	// give the requests a chance to pile up behind the first one
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(codes)
+
+	for code := range codes {
+		if code != 200 {
+			t.Errorf("GET /: got %d, want 200", code)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("init function called %d times, want 1", n)
+	}
+}
+
+func TestOnceError(t *testing.T) {
+	var calls int
+	fail := true
+
+	mux := NewMux()
+	mux.Get("/", Once(func() error {
+		calls++
+		if fail {
+			return errors.New("not yet")
+		}
+		return nil
+	}, echo("/")))
+
+	var tests = []struct {
+		fail  bool
+		code  int
+		body  string
+		calls int
+	}{
+		{true, 500, "Internal server error.\n\n", 1},
+		{true, 500, "Internal server error.\n\n", 2},
+		{false, 200, "/", 3},
+		{true, 200, "/", 3},
+	}
+
+	for i, test := range tests {
+		fail = test.fail
+		w := serve(mux, "GET", "/")
+		if w.Code != test.code || w.Body.String() != test.body || calls != test.calls {
+			t.Errorf("request %d:", i+1)
+			t.Errorf("  got  %d %q after %d calls", w.Code, w.Body.String(), calls)
+			t.Errorf("  want %d %q after %d calls", test.code, test.body, test.calls)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package robo
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// routeDoc holds the documentation attached to a route, for OpenAPI.
+type routeDoc struct {
+	summary   string
+	tags      []string
+	request   interface{}
+	responses map[int]interface{}
+}
+
+// documentation returns the route's documentation, creating it if needed.
+func (r *Route) documentation() *routeDoc {
+	if r.doc == nil {
+		r.doc = new(routeDoc)
+	}
+	return r.doc
+}
+
+// Summary sets the route's summary in the document generated by OpenAPI.
+func (r *Route) Summary(s string) *Route {
+	r.documentation().summary = s
+	return r.publish()
+}
+
+// Tags sets the route's tags in the document generated by OpenAPI.
+func (r *Route) Tags(tags ...string) *Route {
+	r.documentation().tags = tags
+	return r.publish()
+}
+
+// RequestSchema sets the schema of the route's JSON request body, as
+// described by OpenAPI. The schema can be any value which marshals to a
+// valid schema object, like a map[string]interface{}.
+func (r *Route) RequestSchema(schema interface{}) *Route {
+	r.documentation().request = schema
+	return r.publish()
+}
+
+// ResponseSchema sets the schema of the route's JSON response body for a
+// particular status code. A nil schema documents a response without a body.
+func (r *Route) ResponseSchema(status int, schema interface{}) *Route {
+	d := r.documentation()
+	if d.responses == nil {
+		d.responses = make(map[int]interface{})
+	}
+	d.responses[status] = schema
+	return r.publish()
+}
+
+// OpenAPI generates a minimal OpenAPI 3 document describing the routes
+// registered with the Mux, including their path parameters and any
+// documentation attached to them. Routes which accept any method, or end
+// with a wildcard (including mounted Muxes), have no OpenAPI equivalent
+// and are left out. If several routes share a method and path, the one
+// with the highest priority is described.
+func (m *Mux) OpenAPI() ([]byte, error) {
+	paths := make(map[string]map[string]interface{})
+
+	for _, r := range m.snapshot().routes {
+		if r.methods == nil || r.pattern.HasWildcard() {
+			continue
+		}
+
+		path, params := openAPIPath(r.pattern)
+
+		item := paths[path]
+		if item == nil {
+			item = make(map[string]interface{})
+			paths[path] = item
+		}
+
+		for _, method := range r.methods {
+			method = strings.ToLower(method)
+			if _, ok := item[method]; !ok {
+				item[method] = openAPIOperation(r, params)
+			}
+		}
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"openapi": "3.0.3",
+		"info":    map[string]interface{}{"title": "API", "version": "0"},
+		"paths":   paths,
+	})
+}
+
+// openAPIPath converts a pattern to an OpenAPI path template, returning it
+// along with the parameters it declares.
+func openAPIPath(p *Pattern) (string, []interface{}) {
+	var b strings.Builder
+	var params []interface{}
+
+	for _, s := range p.segments {
+		if s.Kind == LiteralSegment {
+			b.WriteString(s.Value)
+			continue
+		}
+
+		b.WriteString("{" + s.Value + "}")
+		params = append(params, map[string]interface{}{
+			"name":     s.Value,
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]interface{}{"type": "string"},
+		})
+	}
+
+	return b.String(), params
+}
+
+// openAPIOperation describes a route as an OpenAPI operation object.
+func openAPIOperation(r *Route, params []interface{}) map[string]interface{} {
+	op := make(map[string]interface{})
+	if params != nil {
+		op["parameters"] = params
+	}
+
+	responses := make(map[string]interface{})
+	op["responses"] = responses
+
+	d := r.doc
+	if d == nil {
+		d = new(routeDoc)
+	}
+
+	if d.summary != "" {
+		op["summary"] = d.summary
+	}
+	if len(d.tags) > 0 {
+		op["tags"] = d.tags
+	}
+	if d.request != nil {
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  openAPIContent(d.request),
+		}
+	}
+
+	for status, schema := range d.responses {
+		resp := map[string]interface{}{"description": statusText(status)}
+		if schema != nil {
+			resp["content"] = openAPIContent(schema)
+		}
+		responses[strconv.Itoa(status)] = resp
+	}
+	if len(responses) == 0 {
+		responses["default"] = map[string]interface{}{"description": "Response."}
+	}
+
+	return op
+}
+
+// openAPIContent describes a JSON body with the given schema.
+func openAPIContent(schema interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": schema},
+	}
+}
+
+// statusText returns a description of a status code.
+func statusText(status int) string {
+	if s := http.StatusText(status); s != "" {
+		return s
+	}
+	return "Response."
+}
@@ -0,0 +1,75 @@
+package robo
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestOpenAPI(t *testing.T) {
+	user := map[string]interface{}{"type": "object"}
+
+	mux := NewMux()
+	mux.Get("/users/{id[0-9]}", echo("/users/{id}")).
+		Summary("Fetch a user").
+		Tags("users").
+		ResponseSchema(200, user).
+		ResponseSchema(404, nil)
+	mux.Add("POST|PUT", "/users", echo("/users")).
+		RequestSchema(user)
+	mux.Get("/static/*", echo("/static/*"))
+	mux.Any("/ping", echo("/ping"))
+
+	buf, err := mux.OpenAPI()
+	if err != nil {
+		t.Fatalf("OpenAPI: %v", err)
+	}
+
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			Summary    string   `json:"summary"`
+			Tags       []string `json:"tags"`
+			Parameters []struct {
+				Name     string `json:"name"`
+				In       string `json:"in"`
+				Required bool   `json:"required"`
+			} `json:"parameters"`
+			RequestBody *struct {
+				Content map[string]interface{} `json:"content"`
+			} `json:"requestBody"`
+			Responses map[string]struct {
+				Description string                 `json:"description"`
+				Content     map[string]interface{} `json:"content"`
+			} `json:"responses"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(buf, &doc); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+
+	if doc.OpenAPI != "3.0.3" || len(doc.Paths) != 2 {
+		t.Fatalf("got version %q and paths %v, want 3.0.3 and 2 paths", doc.OpenAPI, doc.Paths)
+	}
+
+	get := doc.Paths["/users/{id}"]["get"]
+	if get.Summary != "Fetch a user" || fmt.Sprint(get.Tags) != "[users]" {
+		t.Errorf("GET /users/{id}: got summary %q, tags %v", get.Summary, get.Tags)
+	}
+	if len(get.Parameters) != 1 || get.Parameters[0].Name != "id" || get.Parameters[0].In != "path" || !get.Parameters[0].Required {
+		t.Errorf("GET /users/{id}: got parameters %+v", get.Parameters)
+	}
+	if r := get.Responses["200"]; r.Description != "OK" || r.Content["application/json"] == nil {
+		t.Errorf("GET /users/{id}: got 200 response %+v", r)
+	}
+	if r := get.Responses["404"]; r.Description != "Not Found" || r.Content != nil {
+		t.Errorf("GET /users/{id}: got 404 response %+v", r)
+	}
+
+	for _, method := range []string{"post", "put"} {
+		op, ok := doc.Paths["/users"][method]
+		if !ok || op.RequestBody == nil || op.RequestBody.Content["application/json"] == nil {
+			t.Errorf("%s /users: got %+v", method, op)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package robo
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+)
+
+// Parallel returns a handler which runs several handlers concurrently and
+// merges their responses, for composing a response from independent
+// fragments. Each handler receives a clone of the request, with its own
+// data store (initially a copy of the request's) and access to the same
+// URL parameters, and writes to a buffer. The handlers must not call Next.
+//
+// Once all of them have returned, the responses are merged in the order
+// the handlers were given: bodies are concatenated, each header field is
+// taken from the first handler which set it, and the status code is the
+// highest one written, so that an error from any handler wins. Handlers
+// which don't write a status count as 200.
+//
+// If any of the handlers panic, Parallel panics with the first of their
+// values (in the order the handlers were given) once all of them have
+// returned, and nothing is written.
+func Parallel(handlers ...Handler) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		params := make(map[string]string)
+		keys := ParamKeys(r)
+		for _, k := range keys {
+			params[k], _ = lookupParam(r, k)
+		}
+
+		bufs := make([]*bufferWriter, len(handlers))
+		panics := make([]interface{}, len(handlers))
+
+		var wg sync.WaitGroup
+		for i, h := range handlers {
+			bw := &bufferWriter{header: make(http.Header)}
+			bufs[i] = bw
+
+			rr := &Request{Request: r.Request.Clone(r.Context()), params: params, keys: keys, route: r.route}
+			if r.store != nil && *r.store != nil {
+				for k, v := range **r.store {
+					rr.Set(k, v)
+				}
+			}
+
+			wg.Add(1)
+			go func(i int, h Handler) {
+				defer wg.Done()
+				defer func() {
+					panics[i] = recover()
+				}()
+				h.ServeRoboHTTP(bw, rr)
+			}(i, h)
+		}
+		wg.Wait()
+
+		// pass panics on to the calling goroutine, where they can be
+		// handled by OnPanic
+		for _, v := range panics {
+			if v != nil {
+				panic(v)
+			}
+		}
+
+		status := 200
+		h := w.Header()
+
+		for _, bw := range bufs {
+			if bw.status > status {
+				status = bw.status
+			}
+			for k, v := range bw.header {
+				if _, ok := h[k]; !ok {
+					h[k] = v
+				}
+			}
+		}
+
+		w.WriteHeader(status)
+		for _, bw := range bufs {
+			w.Write(bw.buf.Bytes())
+		}
+	})
+}
+
+// bufferWriter is a ResponseWriter which buffers the response in memory.
+type bufferWriter struct {
+	header http.Header
+	status int
+	buf    bytes.Buffer
+}
+
+func (w *bufferWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *bufferWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = 200
+	}
+	return w.buf.Write(p)
+}
@@ -0,0 +1,74 @@
+package robo
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParallel(t *testing.T) {
+	// each handler waits for the other to start, which only succeeds if
+	// they run concurrently
+	var arrived sync.WaitGroup
+	arrived.Add(2)
+
+	both := make(chan bool)
+	go func() {
+		arrived.Wait()
+		close(both)
+	}()
+
+	fragment := func(name string, status int) HandlerFunc {
+		return func(w ResponseWriter, r *Request) {
+			arrived.Done()
+			select {
+			case <-both:
+			case <-time.After(time.Second):
+				t.Errorf("handler %s: handlers don't run concurrently", name)
+			}
+
+			w.Header().Set("Content-Type", "text/"+name)
+			w.Header().Set("X-"+name, r.Param("id")+" "+r.Get("user").(string))
+			w.WriteHeader(status)
+			w.Write([]byte("<" + name + ">"))
+		}
+	}
+
+	mux := NewMux()
+	mux.Get("/dash/{id}", func(w ResponseWriter, r *Request) {
+		r.Set("user", "bob")
+		r.Next(w)
+	}, Parallel(fragment("a", 200), fragment("b", 404)))
+
+	w := serve(mux, "GET", "/dash/7")
+
+	h := w.Header()
+	if w.Code != 404 || w.Body.String() != "<a><b>" || h.Get("Content-Type") != "text/a" ||
+		h.Get("X-A") != "7 bob" || h.Get("X-B") != "7 bob" {
+		t.Errorf("GET /dash/7:")
+		t.Errorf("  got  %d %q (headers %v)", w.Code, w.Body.String(), h)
+		t.Errorf("  want 404 %q (Content-Type text/a, X-A and X-B \"7 bob\")", "<a><b>")
+	}
+}
+
+func TestParallelPanic(t *testing.T) {
+	var recovered interface{}
+
+	mux := NewMux()
+	mux.OnPanic(func(w ResponseWriter, r *Request, v interface{}) {
+		recovered = v
+		w.WriteHeader(500)
+	})
+	mux.Get("/", Parallel(
+		HandlerFunc(func(w ResponseWriter, r *Request) {
+			w.Write([]byte("ok"))
+		}),
+		HandlerFunc(func(w ResponseWriter, r *Request) {
+			panic("fragment failed")
+		}),
+	))
+
+	if w := serve(mux, "GET", "/"); w.Code != 500 || recovered != "fragment failed" {
+		t.Errorf("GET /: got %d (recovered %v), want 500 (recovered %q)", w.Code, recovered, "fragment failed")
+	}
+}
@@ -0,0 +1,88 @@
+package robo
+
+// Pattern is a compiled URL pattern, as accepted by the Mux's registration
+// methods. It can be used to validate and inspect patterns without
+// registering any routes.
+type Pattern struct {
+	s        string
+	segments []Segment
+	matcher  pathMatcher
+}
+
+// SegmentKind identifies the type of a pattern segment.
+type SegmentKind int
+
+const (
+	// LiteralSegment matches a literal string.
+	LiteralSegment SegmentKind = iota
+
+	// ParamSegment captures a named parameter, like "{id}", "{id[0-9]}"
+	// or "{format(json|xml)}".
+	ParamSegment
+
+	// WildcardSegment captures the remainder of the path ("*"), or
+	// matches it without capturing anything ("/...").
+	WildcardSegment
+)
+
+// Segment describes one part of a compiled pattern.
+type Segment struct {
+	Kind SegmentKind
+
+	// Value holds the literal string of a LiteralSegment, the name of a
+	// ParamSegment, or "*" or "..." for a WildcardSegment.
+	Value string
+}
+
+// CompilePattern compiles a URL pattern, returning an error if it is
+// malformed.
+func CompilePattern(pattern string) (*Pattern, error) {
+	fs, err := compileFragments(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	segments := make([]Segment, len(fs))
+	for i, f := range fs {
+		switch f.t {
+		case literalFragment:
+			segments[i] = Segment{LiteralSegment, f.s}
+		case exclusiveFragment, stemFragment, inclusiveFragment, alternativeFragment:
+			segments[i] = Segment{ParamSegment, f.s}
+		case wildcardFragment:
+			segments[i] = Segment{WildcardSegment, "*"}
+		case subtreeFragment:
+			segments[i] = Segment{WildcardSegment, "..."}
+		}
+	}
+
+	return &Pattern{pattern, segments, newMatcher(fs)}, nil
+}
+
+// String returns the pattern's source string.
+func (p *Pattern) String() string {
+	return p.s
+}
+
+// Segments returns the pattern's segments, in order.
+func (p *Pattern) Segments() []Segment {
+	return append([]Segment(nil), p.segments...)
+}
+
+// Params returns the names of the pattern's parameters, in order. The
+// wildcard is not included.
+func (p *Pattern) Params() []string {
+	var names []string
+	for _, s := range p.segments {
+		if s.Kind == ParamSegment {
+			names = append(names, s.Value)
+		}
+	}
+	return names
+}
+
+// HasWildcard reports whether the pattern ends with a wildcard.
+func (p *Pattern) HasWildcard() bool {
+	n := len(p.segments)
+	return n > 0 && p.segments[n-1].Kind == WildcardSegment
+}
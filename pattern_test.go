@@ -0,0 +1,75 @@
+package robo
+
+import (
+	"testing"
+)
+
+var patternTests = []struct {
+	pattern  string
+	err      error
+	segments []Segment
+	params   []string
+	wildcard bool
+}{
+	{"/", nil, []Segment{{LiteralSegment, "/"}}, nil, false},
+	{"/users/{id[0-9]}/{action}", nil, []Segment{
+		{LiteralSegment, "/users/"},
+		{ParamSegment, "id"},
+		{LiteralSegment, "/"},
+		{ParamSegment, "action"},
+	}, []string{"id", "action"}, false},
+	{"/{user}/files/*", nil, []Segment{
+		{LiteralSegment, "/"},
+		{ParamSegment, "user"},
+		{LiteralSegment, "/files/"},
+		{WildcardSegment, "*"},
+	}, []string{"user"}, true},
+	{"/admin/...", nil, []Segment{
+		{LiteralSegment, "/admin/"},
+		{WildcardSegment, "..."},
+	}, nil, true},
+
+	{"", errEmptyPattern, nil, nil, false},
+	{"/{foo", errMissingRBrace, nil, nil, false},
+	{"/*/foo", errIllegalWildcard, nil, nil, false},
+}
+
+func TestCompilePattern(t *testing.T) {
+	for _, test := range patternTests {
+		p, err := CompilePattern(test.pattern)
+		if err != test.err {
+			t.Errorf("CompilePattern(%q):", test.pattern)
+			t.Errorf("  got  %v", err)
+			t.Errorf("  want %v", test.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		segments, params := p.Segments(), p.Params()
+		if len(segments) != len(test.segments) || len(params) != len(test.params) ||
+			p.HasWildcard() != test.wildcard || p.String() != test.pattern {
+			goto fail
+		}
+
+		for i := range segments {
+			if segments[i] != test.segments[i] {
+				goto fail
+			}
+		}
+
+		for i := range params {
+			if params[i] != test.params[i] {
+				goto fail
+			}
+		}
+
+		continue
+
+	fail:
+		t.Errorf("CompilePattern(%q):", test.pattern)
+		t.Errorf("  got  %v %q %v", segments, params, p.HasWildcard())
+		t.Errorf("  want %v %q %v", test.segments, test.params, test.wildcard)
+	}
+}
@@ -0,0 +1,196 @@
+package robo
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	runtimetrace "runtime/trace"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Pprof registers handlers serving runtime profiling data under prefix, in
+// the format expected by the pprof tool: an index at prefix+"/", the
+// cmdline, profile, symbol and trace endpoints, and the named runtime
+// profiles (like prefix+"/heap"). Unless guard is nil, it runs ahead of
+// each of them, and is expected to only call Next for requests allowed to
+// see profiling data.
+//
+// The handlers are built on runtime/pprof rather than net/http/pprof, so
+// nothing is registered on http.DefaultServeMux. The seconds parameter is
+// only supported by the profile and trace endpoints.
+func (m *Mux) Pprof(prefix string, guard Handler) {
+	prefix = strings.TrimRight(prefix, "/")
+
+	with := func(h HandlerFunc) []interface{} {
+		if guard == nil {
+			return []interface{}{h}
+		}
+		return []interface{}{guard, h}
+	}
+
+	m.Get(prefix+"/", with(pprofIndex)...)
+	m.Get(prefix+"/cmdline", with(pprofCmdline)...)
+	m.Get(prefix+"/profile", with(pprofProfile)...)
+	m.Add("GET|POST", prefix+"/symbol", with(pprofSymbol)...)
+	m.Get(prefix+"/trace", with(pprofTrace)...)
+	m.Get(prefix+"/{name}", with(pprofNamed)...)
+}
+
+// pprofIndex lists the available profiles.
+func pprofIndex(w ResponseWriter, r *Request) {
+	profiles := pprof.Profiles()
+	sort.Slice(profiles, func(i, j int) bool {
+		return profiles[i].Name() < profiles[j].Name()
+	})
+
+	var b bytes.Buffer
+	b.WriteString("<html><head><title>Profiles</title></head><body>\n<table>\n")
+	for _, p := range profiles {
+		name := html.EscapeString(p.Name())
+		fmt.Fprintf(&b, "<tr><td>%d</td><td><a href=\"%s?debug=1\">%s</a></td></tr>\n", p.Count(), name, name)
+	}
+	for _, name := range []string{"cmdline", "profile", "symbol", "trace"} {
+		fmt.Fprintf(&b, "<tr><td></td><td><a href=\"%s\">%s</a></td></tr>\n", name, name)
+	}
+	b.WriteString("</table>\n</body></html>\n")
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(b.Bytes())
+}
+
+// pprofCmdline responds with the program's command line, with arguments
+// separated by NUL bytes.
+func pprofCmdline(w ResponseWriter, r *Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	io.WriteString(w, strings.Join(os.Args, "\x00"))
+}
+
+// pprofProfile responds with a CPU profile covering the number of seconds
+// given by the seconds parameter (30 by default).
+func pprofProfile(w ResponseWriter, r *Request) {
+	d := pprofDuration(r, 30)
+
+	setProfileHeaders(w, "profile")
+	if err := pprof.StartCPUProfile(w); err != nil {
+		pprofError(w, 500, "Could not enable CPU profiling: "+err.Error())
+		return
+	}
+	pprofSleep(r, d)
+	pprof.StopCPUProfile()
+}
+
+// pprofTrace responds with an execution trace covering the number of
+// seconds given by the seconds parameter (1 by default).
+func pprofTrace(w ResponseWriter, r *Request) {
+	d := pprofDuration(r, 1)
+
+	setProfileHeaders(w, "trace")
+	if err := runtimetrace.Start(w); err != nil {
+		pprofError(w, 500, "Could not enable tracing: "+err.Error())
+		return
+	}
+	pprofSleep(r, d)
+	runtimetrace.Stop()
+}
+
+// pprofSymbol looks up the names of the program counters listed in the
+// request's body (for POST requests) or querystring, separated by '+'.
+func pprofSymbol(w ResponseWriter, r *Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	var b bytes.Buffer
+	b.WriteString("num_symbols: 1\n")
+
+	var in *bufio.Reader
+	if r.Method == "POST" {
+		in = bufio.NewReader(io.LimitReader(r.Body, 1<<20))
+	} else {
+		in = bufio.NewReader(strings.NewReader(r.URL.RawQuery))
+	}
+
+	for {
+		word, err := in.ReadString('+')
+		if pc, perr := strconv.ParseUint(strings.TrimSuffix(word, "+"), 0, 64); perr == nil && pc != 0 {
+			if fn := runtime.FuncForPC(uintptr(pc)); fn != nil {
+				fmt.Fprintf(&b, "%#x %s\n", pc, fn.Name())
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	w.Write(b.Bytes())
+}
+
+// pprofNamed responds with one of the profiles listed by pprof.Profiles.
+func pprofNamed(w ResponseWriter, r *Request) {
+	name := r.Param("name")
+
+	p := pprof.Lookup(name)
+	if p == nil {
+		pprofError(w, 404, "Unknown profile")
+		return
+	}
+
+	if name == "heap" && r.Query("gc") != "" && r.Query("gc") != "0" {
+		runtime.GC()
+	}
+
+	debug, _ := strconv.Atoi(r.Query("debug"))
+	if debug != 0 {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+	} else {
+		setProfileHeaders(w, name)
+	}
+
+	p.WriteTo(w, debug)
+}
+
+// pprofDuration parses the request's seconds parameter.
+func pprofDuration(r *Request, def int) time.Duration {
+	sec, err := strconv.ParseInt(r.Query("seconds"), 10, 64)
+	if err != nil || sec <= 0 {
+		sec = int64(def)
+	}
+	return time.Duration(sec) * time.Second
+}
+
+// pprofSleep waits for d, or until the request is cancelled.
+func pprofSleep(r *Request, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+	case <-r.Context().Done():
+	}
+}
+
+// setProfileHeaders prepares a response for a binary profile.
+func setProfileHeaders(w ResponseWriter, name string) {
+	h := w.Header()
+	h.Set("Content-Type", "application/octet-stream")
+	h.Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	h.Set("X-Content-Type-Options", "nosniff")
+}
+
+// pprofError responds with a plain error message, undoing the headers set
+// by setProfileHeaders.
+func pprofError(w ResponseWriter, code int, msg string) {
+	h := w.Header()
+	h.Del("Content-Disposition")
+	http.Error(w, msg+"\n", code)
+}
@@ -0,0 +1,72 @@
+package robo
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPprof(t *testing.T) {
+	guard := HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.Header.Get("Authorization") != "secret" {
+			http.Error(w, "Unauthorized.\n", 401)
+			return
+		}
+		r.Next(w)
+	})
+
+	mux := NewMux()
+	mux.Pprof("/debug/profiling/", guard)
+
+	var tests = []struct {
+		path string
+		auth string
+		code int
+		body string
+	}{
+		{"/debug/profiling/", "secret", 200, "goroutine"},
+		{"/debug/profiling/", "", 401, "Unauthorized."},
+		{"/debug/profiling/cmdline", "secret", 200, ""},
+		{"/debug/profiling/goroutine?debug=1", "secret", 200, "goroutine profile:"},
+		{"/debug/profiling/goroutine", "wrong", 401, "Unauthorized."},
+		{"/debug/profiling/missing", "secret", 404, "Unknown profile"},
+	}
+
+	for _, test := range tests {
+		hr := httptest.NewRequest("GET", test.path, nil)
+		if test.auth != "" {
+			hr.Header.Set("Authorization", test.auth)
+		}
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, hr)
+
+		if w.Code != test.code || !strings.Contains(w.Body.String(), test.body) {
+			t.Errorf("GET %s (Authorization %q):", test.path, test.auth)
+			t.Errorf("  got  %d %.60q", w.Code, w.Body.String())
+			t.Errorf("  want %d, body containing %q", test.code, test.body)
+		}
+	}
+
+	// the handlers must not be registered on http.DefaultServeMux
+	if _, pattern := http.DefaultServeMux.Handler(httptest.NewRequest("GET", "/debug/pprof/", nil)); pattern != "" {
+		t.Errorf("http.DefaultServeMux serves /debug/pprof/ with pattern %q", pattern)
+	}
+
+	hr := httptest.NewRequest("GET", "/debug/profiling/symbol?"+fmt.Sprintf("%#x", reflect.ValueOf(TestPprof).Pointer()), nil)
+	hr.Header.Set("Authorization", "secret")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, hr)
+	if !strings.HasPrefix(w.Body.String(), "num_symbols: 1\n") || !strings.Contains(w.Body.String(), "TestPprof") {
+		t.Errorf("GET /debug/profiling/symbol: got %q, want a line naming TestPprof", w.Body.String())
+	}
+
+	open := NewMux()
+	open.Pprof("/pprof", nil)
+	if w := serve(open, "GET", "/pprof/"); w.Code != 200 {
+		t.Errorf("GET /pprof/ without a guard: got %d, want 200", w.Code)
+	}
+}
@@ -0,0 +1,80 @@
+package robo
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// parseNetworks parses a list of networks in CIDR notation, or plain IP
+// addresses, panicking if any of them are malformed.
+func parseNetworks(list []string) []*net.IPNet {
+	var nets []*net.IPNet
+
+	for _, s := range list {
+		if !strings.Contains(s, "/") {
+			if ip := net.ParseIP(s); ip == nil {
+				panic("robo: invalid IP address " + s)
+			} else if ip.To4() != nil {
+				s += "/32"
+			} else {
+				s += "/128"
+			}
+		}
+
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			panic("robo: invalid network " + s)
+		}
+
+		nets = append(nets, n)
+	}
+
+	return nets
+}
+
+// fromTrustedProxy reports whether the request's immediate peer belongs
+// to one of the trusted networks.
+func fromTrustedProxy(r *http.Request, trusted []*net.IPNet) bool {
+	if len(trusted) == 0 {
+		return false
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// requestScheme returns the effective scheme ("http" or "https") of a
+// request, honoring the X-Forwarded-Proto header of trusted proxies.
+func requestScheme(r *http.Request, trusted []*net.IPNet) string {
+	if r.TLS != nil {
+		return "https"
+	}
+
+	if fromTrustedProxy(r, trusted) {
+		proto := r.Header.Get("X-Forwarded-Proto")
+		if i := strings.IndexByte(proto, ','); i >= 0 {
+			proto = proto[:i]
+		}
+		if strings.EqualFold(strings.TrimSpace(proto), "https") {
+			return "https"
+		}
+	}
+
+	return "http"
+}
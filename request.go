@@ -3,6 +3,7 @@ package robo
 import (
 	"net/http"
 	"net/url"
+	"strings"
 )
 
 // The Request type extends an http.Request instance with additional
@@ -13,20 +14,33 @@ type Request struct {
 	// parsed querystring values (lazily generated)
 	query url.Values
 
-	// named URL parameters, specific to the route
+	// named URL parameters, specific to the route, and their names in the
+	// order they appear in the route's pattern
 	params map[string]string
+	keys   []string
 
 	// pointer to the request-local data map, which is stored in the
-	// queue and shared between all routes
+	// queue and shared between all routes (and mounted Muxes)
 	store **map[string]interface{}
 
 	// reference to the request's queue, used by the Next method
 	queue *queue
+
+	// the route being served, or nil for failure handlers
+	route *Route
+
+	// position of the handler in its chain, starting at 1
+	depth int
 }
 
 // Next yields execution to the next matching handler, if there is one,
-// blocking until said handler has returned.
+// blocking until said handler has returned. When called on a Request which
+// wasn't created by a Mux, Next responds with a plain 404.
 func (r *Request) Next(w ResponseWriter) {
+	if r.queue == nil {
+		http.Error(w, "Not found.\n", 404)
+		return
+	}
 	r.queue.serveNext(w, r.Request)
 }
 
@@ -39,15 +53,29 @@ func (r *Request) Query(name string) string {
 	return r.query.Get(name)
 }
 
-// Param returns the value of a named URL parameter.
+// Param returns the value of a named URL parameter. Requests routed through
+// a mounted Mux also have access to the parameters captured by the parent
+// Mux. The zero Request has no parameters.
 func (r *Request) Param(name string) string {
-	return r.params[name]
+	v, _ := lookupParam(r, name)
+	return v
+}
+
+// lookupParam looks up a named URL parameter, like Param, while also
+// reporting whether it was captured at all.
+func lookupParam(r *Request, name string) (string, bool) {
+	for ; r != nil; r = parentRequest(r) {
+		if v, ok := r.params[name]; ok {
+			return v, true
+		}
+	}
+	return "", false
 }
 
 // Get returns a value stored in the request's data store (or nil if
 // it hasn't been defined yet).
 func (r *Request) Get(key string) interface{} {
-	if *r.store == nil {
+	if r.store == nil || *r.store == nil {
 		return nil
 	}
 	return (**r.store)[key]
@@ -55,9 +83,132 @@ func (r *Request) Get(key string) interface{} {
 
 // Set stores a value in the request's data store.
 func (r *Request) Set(key string, value interface{}) {
+	if r.store == nil {
+		r.store = new(*map[string]interface{})
+	}
 	if *r.store == nil {
 		m := make(map[string]interface{})
 		*r.store = &m
 	}
 	(**r.store)[key] = value
 }
+
+// RemainingPath returns the part of the request's path which wasn't
+// consumed by the current route's pattern, which is the value captured by
+// its trailing wildcard, or "" for routes without one. Routes of a mounted
+// Mux are matched against paths with the mount prefix already stripped, so
+// the remainder is relative to that prefix.
+func RemainingPath(r *Request) string {
+	return r.params["*"]
+}
+
+// MatchRemainder matches the path remainder captured by the current route's
+// trailing wildcard (see RemainingPath) against p, a pattern compiled with
+// CompilePattern, so that invalid patterns are caught when the handler is
+// set up rather than while serving. On success it returns a copy of r
+// which also has access to the parameters captured by p, shadowing any
+// outer parameters of the same name. This allows a catch-all handler to do
+// a second stage of routing without mounting another Mux. A leading slash
+// is added to the remainder if it doesn't have one.
+func MatchRemainder(r *Request, p *Pattern) (*Request, bool) {
+	rest := RemainingPath(r)
+	if !strings.HasPrefix(rest, "/") {
+		rest = "/" + rest
+	}
+
+	ok, list := p.matcher.match(rest, nil)
+	if !ok {
+		return nil, false
+	}
+
+	params := make(map[string]string, len(r.params)+len(list)/2)
+	keys := make([]string, 0, len(r.keys)+len(list)/2)
+	for i := 0; i < len(list); i += 2 {
+		if _, dup := params[list[i]]; !dup {
+			keys = append(keys, list[i])
+		}
+		params[list[i]] = list[i+1]
+	}
+	for _, k := range r.keys {
+		if _, dup := params[k]; !dup {
+			keys = append(keys, k)
+			params[k] = r.params[k]
+		}
+	}
+
+	c := *r
+	c.query = nil
+	c.params = params
+	c.keys = keys
+	return &c, true
+}
+
+// ParamKeys returns the names of the URL parameters available to r, in the
+// order they appear in the matched route's pattern, followed by those of
+// the routes matched by any parent Muxes (outermost last). Names shadowed by
+// an inner route are only listed once.
+func ParamKeys(r *Request) []string {
+	var keys []string
+	seen := make(map[string]bool)
+
+	for ; r != nil; r = parentRequest(r) {
+		for _, k := range r.keys {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+
+	return keys
+}
+
+// parentRequest returns the Request passed to the Mux which created r, if
+// that Mux was mounted in another one.
+func parentRequest(r *Request) *Request {
+	if r.queue == nil {
+		return nil
+	}
+	return r.queue.parent
+}
+
+// PatternFromRequest returns the pattern of the route serving r, as it was
+// registered, regardless of how the request's path was spelled (like with
+// or without a trailing slash, under StrictSlash). It returns "" for
+// requests served by NotFound or Fallback handlers, and requests not
+// created by a Mux.
+func PatternFromRequest(r *Request) string {
+	if r.route == nil {
+		return ""
+	}
+	return r.route.pattern.String()
+}
+
+// SpanName returns a name for r suitable for tracing spans and metrics,
+// made up of the request's method and the pattern of the route serving it,
+// as in "GET /users/{id}". For requests routed through mounted Muxes, the
+// patterns of the mount points are included, so a route "/{id}" in a Mux
+// mounted at "/users" yields the same name. Requests which didn't match a
+// route are named by their method alone, to keep the cardinality low.
+func SpanName(r *Request) string {
+	if r.route == nil {
+		return r.Method
+	}
+
+	pattern := r.route.pattern.String()
+	for p := parentRequest(r); p != nil && p.route != nil; p = parentRequest(p) {
+		prefix := strings.TrimSuffix(p.route.pattern.String(), "*")
+		pattern = strings.TrimSuffix(prefix, "/") + pattern
+	}
+
+	return r.Method + " " + pattern
+}
+
+// ChainDepth returns the position of the handler serving r within the chain
+// of handlers for the current route, including any middleware registered
+// with Use. The first handler in a chain sees 1, the handler it yields to
+// by calling Next sees 2, and so on. It returns 0 for requests not created
+// by a Mux.
+func ChainDepth(r *Request) int {
+	return r.depth
+}
@@ -0,0 +1,257 @@
+package robo
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBareRequest(t *testing.T) {
+	r := &Request{Request: httptest.NewRequest("GET", "/?q=1", nil)}
+
+	if v := r.Param("id"); v != "" {
+		t.Errorf("Param(%q) = %q, want %q", "id", v, "")
+	}
+	if v := r.Get("key"); v != nil {
+		t.Errorf("Get(%q) = %v, want nil", "key", v)
+	}
+
+	r.Set("key", 1)
+	if v := r.Get("key"); v != 1 {
+		t.Errorf("Get(%q) = %v after Set, want 1", "key", v)
+	}
+
+	if v := r.Query("q"); v != "1" {
+		t.Errorf("Query(%q) = %q, want %q", "q", v, "1")
+	}
+
+	w := httptest.NewRecorder()
+	r.Next(w)
+	if w.Code != 404 {
+		t.Errorf("Next: got status %d, want 404", w.Code)
+	}
+}
+
+func TestInitialRequest(t *testing.T) {
+	var id string
+	var value interface{}
+
+	mux := NewMux()
+	mux.Any("*", func(w ResponseWriter, r *Request) {
+		id = r.Param("id")
+		value = r.Get("key")
+		r.Set("key", "value")
+		r.Next(w)
+	})
+	mux.Get("/{id}", func(w ResponseWriter, r *Request) {
+		value = r.Get("key")
+	})
+
+	serve(mux, "GET", "/foo")
+	if id != "" || value != "value" {
+		t.Errorf("got Param(%q) = %q, Get(%q) = %v", "id", id, "key", value)
+		t.Errorf("want Param(%q) = %q, Get(%q) = %v", "id", "", "key", "value")
+	}
+}
+
+func TestRemainingPath(t *testing.T) {
+	var rest string
+	remember := func(w ResponseWriter, r *Request) {
+		rest = RemainingPath(r)
+	}
+
+	child := NewMux()
+	child.Get("/fixed", remember)
+	child.Get("*", remember)
+
+	mux := NewMux()
+	mux.Mount("/sub", child)
+	mux.Get("/files/*", remember)
+
+	var tests = []struct {
+		path string
+		rest string
+	}{
+		{"/files/", ""},
+		{"/files/a/b.txt", "a/b.txt"},
+		{"/sub", "/"},
+		{"/sub/a/b", "/a/b"},
+		{"/sub/fixed", ""},
+	}
+
+	for _, test := range tests {
+		rest = "-"
+		if serve(mux, "GET", test.path); rest != test.rest {
+			t.Errorf("GET %s: RemainingPath = %q, want %q", test.path, rest, test.rest)
+		}
+	}
+}
+
+func TestChainDepth(t *testing.T) {
+	var depths []int
+	record := func(w ResponseWriter, r *Request) {
+		depths = append(depths, ChainDepth(r))
+		r.Next(w)
+		depths = append(depths, ChainDepth(r))
+	}
+
+	mux := NewMux()
+	mux.Use(record)
+	mux.Get("/", record, record, record)
+
+	serve(mux, "GET", "/")
+
+	want := []int{1, 2, 3, 4, 4, 3, 2, 1}
+	if fmt.Sprint(depths) != fmt.Sprint(want) {
+		t.Errorf("ChainDepth:")
+		t.Errorf("  got  %v", depths)
+		t.Errorf("  want %v", want)
+	}
+}
+
+func TestParamKeys(t *testing.T) {
+	var keys []string
+	remember := func(w ResponseWriter, r *Request) {
+		keys = ParamKeys(r)
+	}
+
+	mux := NewMux()
+	mux.Get("/{z}/{a}/{m}", remember)
+	mux.Get("/files/{name}.{ext}", remember)
+	mux.Host("{tenant}.example.com").Get("/{y}/{b}", remember)
+
+	var tests = []struct {
+		host string
+		path string
+		keys []string
+	}{
+		{"example.com", "/1/2/3", []string{"z", "a", "m"}},
+		{"example.com", "/files/a.b.c", []string{"name", "ext"}},
+		{"acme.example.com", "/1/2", []string{"y", "b", "tenant"}},
+		{"example.com", "/", nil},
+	}
+
+	for _, test := range tests {
+		keys = nil
+
+		r := httptest.NewRequest("GET", "http://"+test.host+test.path, nil)
+		mux.ServeHTTP(httptest.NewRecorder(), r)
+
+		if fmt.Sprint(keys) != fmt.Sprint(test.keys) {
+			t.Errorf("GET %s%s:", test.host, test.path)
+			t.Errorf("  got  %q", keys)
+			t.Errorf("  want %q", test.keys)
+		}
+	}
+
+	if keys := ParamKeys(&Request{}); keys != nil {
+		t.Errorf("ParamKeys(&Request{}) = %q, want nil", keys)
+	}
+}
+
+func TestPatternFromRequest(t *testing.T) {
+	var pattern string
+	remember := func(w ResponseWriter, r *Request) {
+		pattern = PatternFromRequest(r)
+	}
+
+	mux := NewMux()
+	mux.StrictSlash(true)
+	mux.Get("/users", remember)
+	mux.Get("/users/{id}/", remember)
+	mux.NotFound(remember)
+
+	var tests = []struct {
+		path    string
+		pattern string
+	}{
+		{"/users", "/users"},
+		{"/users/", "/users"},
+		{"/users/5", "/users/{id}/"},
+		{"/users/5/", "/users/{id}/"},
+		{"/missing", ""},
+	}
+
+	for _, test := range tests {
+		pattern = "-"
+		if serve(mux, "GET", test.path); pattern != test.pattern {
+			t.Errorf("GET %s: PatternFromRequest = %q, want %q", test.path, pattern, test.pattern)
+		}
+	}
+}
+
+func TestMatchRemainder(t *testing.T) {
+	users, err := CompilePattern("/users/{id}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenant, err := CompilePattern("/{tenant}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := NewMux()
+	mux.Get("/t/{tenant}/*", func(w ResponseWriter, r *Request) {
+		if sub, ok := MatchRemainder(r, users); ok {
+			fmt.Fprintf(w, "user %s/%s %v", sub.Param("tenant"), sub.Param("id"), ParamKeys(sub))
+			return
+		}
+		if sub, ok := MatchRemainder(r, tenant); ok {
+			fmt.Fprintf(w, "shadow %s (outer %s)", sub.Param("tenant"), r.Param("tenant"))
+			return
+		}
+		fmt.Fprint(w, "no match")
+	})
+
+	var tests = []struct {
+		path string
+		body string
+	}{
+		{"/t/acme/users/5", "user acme/5 [id tenant *]"},
+		{"/t/acme/users/5/x", "no match"},
+		{"/t/acme/other", "shadow other (outer acme)"},
+		{"/t/acme/", "no match"},
+	}
+
+	for _, test := range tests {
+		if w := serve(mux, "GET", test.path); w.Body.String() != test.body {
+			t.Errorf("GET %s: got %q, want %q", test.path, w.Body.String(), test.body)
+		}
+	}
+}
+
+func TestSpanName(t *testing.T) {
+	var name string
+	remember := func(w ResponseWriter, r *Request) {
+		name = SpanName(r)
+	}
+
+	users := NewMux()
+	users.Get("/{id}", remember)
+
+	mux := NewMux()
+	mux.Mount("/users", users)
+	mux.Add("GET|POST", "/items/{id}", remember)
+	mux.Host("{tenant}.example.com").Get("/", remember)
+	mux.NotFound(remember)
+
+	var tests = []struct {
+		method string
+		target string
+		name   string
+	}{
+		{"GET", "/items/5", "GET /items/{id}"},
+		{"POST", "/items/6", "POST /items/{id}"},
+		{"GET", "/users/5", "GET /users/{id}"},
+		{"GET", "http://acme.example.com/", "GET /"},
+		{"GET", "/missing", "GET"},
+		{"DELETE", "/items/5", ""},
+	}
+
+	for _, test := range tests {
+		name = ""
+		if serve(mux, test.method, test.target); name != test.name {
+			t.Errorf("%s %s: SpanName = %q, want %q", test.method, test.target, name, test.name)
+		}
+	}
+}
@@ -0,0 +1,382 @@
+package robo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// jsonSchema is a compiled JSON Schema, limited to the subset of keywords
+// understood by ValidateJSON.
+type jsonSchema struct {
+	Type       json.RawMessage        `json:"type"`
+	Enum       []interface{}          `json:"enum"`
+	Properties map[string]*jsonSchema `json:"properties"`
+	Required   []string               `json:"required"`
+	Additional json.RawMessage        `json:"additionalProperties"`
+	Items      *jsonSchema            `json:"items"`
+	Minimum    *float64               `json:"minimum"`
+	Maximum    *float64               `json:"maximum"`
+	MinLength  *int                   `json:"minLength"`
+	MaxLength  *int                   `json:"maxLength"`
+	MinItems   *int                   `json:"minItems"`
+	MaxItems   *int                   `json:"maxItems"`
+	Pattern    string                 `json:"pattern"`
+
+	types  []string
+	closed bool
+	extra  *jsonSchema
+	re     *regexp.Regexp
+}
+
+// schemaKeywords lists the keywords understood by ValidateJSON, along with
+// the annotations which don't affect validation, and so are safe to ignore.
+var schemaKeywords = map[string]bool{
+	"type": true, "enum": true, "properties": true, "required": true,
+	"additionalProperties": true, "items": true, "minimum": true,
+	"maximum": true, "minLength": true, "maxLength": true, "minItems": true,
+	"maxItems": true, "pattern": true,
+
+	"$schema": true, "$id": true, "$comment": true, "title": true,
+	"description": true, "default": true, "examples": true,
+	"deprecated": true, "readOnly": true, "writeOnly": true,
+}
+
+// maxSchemaBody is the largest request body ValidateJSON buffers.
+const maxSchemaBody = 1 << 20
+
+// schemaError describes a single validation failure. Path is a JSON
+// Pointer to the offending value, which is "" for the document itself.
+type schemaError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// ValidateJSON returns a middleware handler which validates request bodies
+// against a JSON Schema before calling Next. The body is buffered, and
+// handlers further down the chain can read it as usual.
+//
+// Bodies which aren't valid JSON are rejected with a plain 400, while
+// bodies which don't satisfy the schema get a 422 with a JSON description
+// of each failure, like:
+//
+//	{"errors":[{"path":"/name","message":"is required"}]}
+//
+// Bodies larger than 1 MiB are rejected with a 413 without being
+// validated; MaxBytes or Route.MaxBody can impose a lower limit.
+//
+// Only a subset of JSON Schema is supported: "type", "enum", "properties",
+// "required", "additionalProperties", "items", "minimum", "maximum",
+// "minLength", "maxLength", "minItems", "maxItems" and "pattern", along
+// with annotations like "title" and "description". ValidateJSON panics if
+// schema can't be parsed, or uses any other keyword, rather than silently
+// accepting requests the schema was meant to reject.
+func ValidateJSON(schema []byte) Handler {
+	if err := checkKeywords(schema); err != nil {
+		panic(err)
+	}
+
+	s := new(jsonSchema)
+	if err := json.Unmarshal(schema, s); err != nil {
+		panic(err)
+	}
+	if err := s.compile(); err != nil {
+		panic(err)
+	}
+
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		var buf []byte
+
+		if r.Body != nil {
+			var err error
+			if buf, err = io.ReadAll(io.LimitReader(r.Body, maxSchemaBody+1)); err != nil {
+				var mbe *http.MaxBytesError
+				if errors.As(err, &mbe) {
+					http.Error(w, "Request entity too large.\n", 413)
+				} else {
+					http.Error(w, "Bad request.\n", 400)
+				}
+				return
+			}
+			r.Body.Close()
+
+			if len(buf) > maxSchemaBody {
+				http.Error(w, "Request entity too large.\n", 413)
+				return
+			}
+		}
+
+		var v interface{}
+		if err := json.Unmarshal(buf, &v); err != nil {
+			http.Error(w, "Bad request.\n", 400)
+			return
+		}
+
+		if errs := s.validate("", v, nil); len(errs) > 0 {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(422)
+			json.NewEncoder(w).Encode(struct {
+				Errors []schemaError `json:"errors"`
+			}{errs})
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(buf))
+		r.Next(w)
+	})
+}
+
+// checkKeywords returns an error if a schema, or any of its subschemas,
+// uses a keyword ValidateJSON doesn't understand.
+func checkKeywords(schema []byte) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(schema, &m); err != nil {
+		return err
+	}
+
+	for k, v := range m {
+		if !schemaKeywords[k] {
+			return fmt.Errorf("robo: unsupported JSON Schema keyword %q", k)
+		}
+
+		switch k {
+		case "properties":
+			var props map[string]json.RawMessage
+			if err := json.Unmarshal(v, &props); err != nil {
+				return err
+			}
+			for _, p := range props {
+				if err := checkKeywords(p); err != nil {
+					return err
+				}
+			}
+
+		case "items":
+			if err := checkKeywords(v); err != nil {
+				return err
+			}
+
+		case "additionalProperties":
+			if b := bytes.TrimSpace(v); len(b) > 0 && b[0] == '{' {
+				if err := checkKeywords(b); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// compile prepares s and its subschemas for validation.
+func (s *jsonSchema) compile() error {
+	switch t := bytes.TrimSpace(s.Type); {
+	case len(t) == 0:
+	case t[0] == '[':
+		if err := json.Unmarshal(t, &s.types); err != nil {
+			return err
+		}
+	default:
+		var name string
+		if err := json.Unmarshal(t, &name); err != nil {
+			return err
+		}
+		s.types = []string{name}
+	}
+
+	switch a := bytes.TrimSpace(s.Additional); {
+	case len(a) == 0, string(a) == "true":
+	case string(a) == "false":
+		s.closed = true
+	default:
+		s.extra = new(jsonSchema)
+		if err := json.Unmarshal(a, s.extra); err != nil {
+			return err
+		}
+		if err := s.extra.compile(); err != nil {
+			return err
+		}
+	}
+
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return err
+		}
+		s.re = re
+	}
+
+	for _, p := range s.Properties {
+		if err := p.compile(); err != nil {
+			return err
+		}
+	}
+
+	if s.Items != nil {
+		return s.Items.compile()
+	}
+
+	return nil
+}
+
+// validate appends a schemaError to errs for each way v, found at path,
+// fails to satisfy s.
+func (s *jsonSchema) validate(path string, v interface{}, errs []schemaError) []schemaError {
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, schemaError{path, fmt.Sprintf(format, args...)})
+	}
+
+	if s.types != nil && !s.hasType(v) {
+		fail("must be of type %s", joinTypes(s.types))
+		return errs
+	}
+
+	if s.Enum != nil {
+		for _, e := range s.Enum {
+			if reflect.DeepEqual(e, v) {
+				goto ok
+			}
+		}
+		fail("must be one of the enumerated values")
+	ok:
+	}
+
+	switch v := v.(type) {
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			fail("must be at least %v", *s.Minimum)
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			fail("must be at most %v", *s.Maximum)
+		}
+
+	case string:
+		n := utf8.RuneCountInString(v)
+		if s.MinLength != nil && n < *s.MinLength {
+			fail("must be at least %d characters long", *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			fail("must be at most %d characters long", *s.MaxLength)
+		}
+		if s.re != nil && !s.re.MatchString(v) {
+			fail("must match the pattern %q", s.Pattern)
+		}
+
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			fail("must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			fail("must have at most %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, e := range v {
+				errs = s.Items.validate(path+"/"+strconv.Itoa(i), e, errs)
+			}
+		}
+
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				errs = append(errs, schemaError{path + "/" + escapePointer(name), "is required"})
+			}
+		}
+
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			sub := path + "/" + escapePointer(k)
+			switch p, ok := s.Properties[k]; {
+			case ok:
+				errs = p.validate(sub, v[k], errs)
+			case s.extra != nil:
+				errs = s.extra.validate(sub, v[k], errs)
+			case s.closed:
+				errs = append(errs, schemaError{sub, "is not allowed"})
+			}
+		}
+	}
+
+	return errs
+}
+
+// hasType reports whether v is of one of the types listed by s.
+func (s *jsonSchema) hasType(v interface{}) bool {
+	for _, t := range s.types {
+		switch v := v.(type) {
+		case nil:
+			if t == "null" {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case float64:
+			if t == "number" || t == "integer" && v == math.Trunc(v) {
+				return true
+			}
+		case string:
+			if t == "string" {
+				return true
+			}
+		case []interface{}:
+			if t == "array" {
+				return true
+			}
+		case map[string]interface{}:
+			if t == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// joinTypes formats a list of type names for an error message.
+func joinTypes(types []string) string {
+	if len(types) == 1 {
+		return types[0]
+	}
+
+	var b bytes.Buffer
+	for i, t := range types {
+		switch {
+		case i == len(types)-1:
+			b.WriteString(" or ")
+		case i > 0:
+			b.WriteString(", ")
+		}
+		b.WriteString(t)
+	}
+	return b.String()
+}
+
+// escapePointer escapes a property name for use in a JSON Pointer.
+func escapePointer(name string) string {
+	var b bytes.Buffer
+	for i := 0; i < len(name); i++ {
+		switch name[i] {
+		case '~':
+			b.WriteString("~0")
+		case '/':
+			b.WriteString("~1")
+		default:
+			b.WriteByte(name[i])
+		}
+	}
+	return b.String()
+}
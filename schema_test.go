@@ -0,0 +1,80 @@
+package robo
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"title": "Person",
+	"type": "object",
+	"properties": {
+		"name": {"type": "string", "minLength": 1, "description": "Full name"},
+		"age":  {"type": "integer", "minimum": 0},
+		"tags": {"type": "array", "items": {"enum": ["a", "b"]}}
+	},
+	"required": ["name"],
+	"additionalProperties": false
+}`
+
+func TestValidateJSON(t *testing.T) {
+	mux := NewMux()
+	mux.Use(ValidateJSON([]byte(testSchema)))
+	mux.Post("/", func(w ResponseWriter, r *Request) {
+		buf, _ := io.ReadAll(r.Body)
+		w.Write(buf)
+	})
+
+	var tests = []struct {
+		body string
+		code int
+		resp string
+	}{
+		{`{"name":"x","age":3,"tags":["a"]}`, 200, `{"name":"x","age":3,"tags":["a"]}`},
+		{`{"name":"x"}`, 200, `{"name":"x"}`},
+		{`{"age":-1.5}`, 422, `{"errors":[` +
+			`{"path":"/name","message":"is required"},` +
+			`{"path":"/age","message":"must be of type integer"}]}` + "\n"},
+		{`{"name":"","tags":["a","c"],"x":1}`, 422, `{"errors":[` +
+			`{"path":"/name","message":"must be at least 1 characters long"},` +
+			`{"path":"/tags/1","message":"must be one of the enumerated values"},` +
+			`{"path":"/x","message":"is not allowed"}]}` + "\n"},
+		{`[]`, 422, `{"errors":[{"path":"","message":"must be of type object"}]}` + "\n"},
+		{`{"name":`, 400, "Bad request.\n\n"},
+		{``, 400, "Bad request.\n\n"},
+		{`{"name":"` + strings.Repeat("x", maxSchemaBody) + `"}`, 413, "Request entity too large.\n\n"},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader(test.body)))
+
+		if w.Code != test.code || w.Body.String() != test.resp {
+			t.Errorf("POST %s:", test.body)
+			t.Errorf("  got  %d %s", w.Code, w.Body.String())
+			t.Errorf("  want %d %s", test.code, test.resp)
+		}
+	}
+}
+
+func TestValidateJSONInvalidSchema(t *testing.T) {
+	for _, schema := range []string{`{`, `{"type": 5}`, `{"pattern": "("}`,
+		`{"oneOf": [{"type": "string"}]}`,
+		`{"properties": {"id": {"$ref": "#/definitions/id"}}}`,
+		`{"items": {"const": 1}}`,
+		`{"additionalProperties": {"format": "email"}}`,
+		`{"type": "number", "exclusiveMinimum": 0}`,
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("ValidateJSON(%s) didn't panic", schema)
+				}
+			}()
+			ValidateJSON([]byte(schema))
+		}()
+	}
+}
@@ -0,0 +1,80 @@
+package robo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// RouteSpec describes a route declaratively, for use with LoadTable.
+type RouteSpec struct {
+	Method  string
+	Pattern string
+
+	// Name identifies the route's handler in LoadTable's registry.
+	Name string
+}
+
+// LoadTable registers a table of routes, resolving each route's handler by
+// name from registry. All routes are validated before any are registered;
+// if any route has an unknown handler name, or an invalid method, pattern
+// or handler, nothing is registered and the errors are returned together.
+func (m *Mux) LoadTable(routes []RouteSpec, registry map[string]interface{}) error {
+	var errs []error
+	var clean []*Route
+
+	for i, spec := range routes {
+		r, err := specRoute(spec, registry)
+		if err != nil {
+			errs = append(errs, &specError{i, spec, err})
+			continue
+		}
+		clean = append(clean, r)
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	for _, r := range clean {
+		m.insert(r)
+	}
+
+	return nil
+}
+
+// A specError describes why the route at index i of a table is invalid.
+type specError struct {
+	i    int
+	spec RouteSpec
+	err  error
+}
+
+func (e *specError) Error() string {
+	// most errors already carry the package prefix
+	msg := strings.TrimPrefix(e.err.Error(), "robo: ")
+	return fmt.Sprintf("robo: route %d (%s %s): %s", e.i, e.spec.Method, e.spec.Pattern, msg)
+}
+
+func (e *specError) Unwrap() error {
+	return e.err
+}
+
+// specRoute builds a route from a RouteSpec.
+func specRoute(spec RouteSpec, registry map[string]interface{}) (*Route, error) {
+	if spec.Method == "" {
+		return nil, errEmptyMethod
+	}
+
+	h, ok := registry[spec.Name]
+	if !ok {
+		return nil, fmt.Errorf("unknown handler %q", spec.Name)
+	}
+
+	handlers, err := adaptHandlers([]interface{}{h})
+	if err != nil {
+		return nil, err
+	}
+
+	return newRoute(spec.Method, spec.Pattern, handlers)
+}
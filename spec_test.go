@@ -0,0 +1,52 @@
+package robo
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLoadTable(t *testing.T) {
+	registry := map[string]interface{}{
+		"users.list": echo("users.list"),
+		"users.show": func(w ResponseWriter, r *Request) {
+			w.Write([]byte("users.show " + r.Param("id")))
+		},
+	}
+
+	mux := NewMux()
+	err := mux.LoadTable([]RouteSpec{
+		{"GET", "/users", "users.list"},
+		{"GET", "/users/{id}", "users.show"},
+	}, registry)
+	if err != nil {
+		t.Fatalf("LoadTable: %v", err)
+	}
+
+	if w := serve(mux, "GET", "/users/5"); w.Body.String() != "users.show 5" {
+		t.Errorf("GET /users/5: got %q, want %q", w.Body.String(), "users.show 5")
+	}
+	if w := serve(mux, "GET", "/users"); w.Body.String() != "users.list" {
+		t.Errorf("GET /users: got %q, want %q", w.Body.String(), "users.list")
+	}
+
+	bad := NewMux()
+	err = bad.LoadTable([]RouteSpec{
+		{"GET", "/ok", "users.list"},
+		{"GET", "/missing", "users.delete"},
+		{"GET", "/{broken", "users.show"},
+	}, registry)
+
+	if err == nil || !errors.Is(err, errMissingRBrace) || !strings.Contains(err.Error(), `unknown handler "users.delete"`) {
+		t.Errorf("LoadTable with invalid routes: got %v", err)
+	}
+	if want := `robo: route 1 (GET /missing): unknown handler "users.delete"`; !strings.HasPrefix(err.Error(), want+"\n") {
+		t.Errorf("LoadTable with invalid routes: got %q, want it to start with %q", err, want)
+	}
+	if strings.Count(err.Error(), "robo: ") != 2 {
+		t.Errorf("LoadTable with invalid routes: got %q, want one prefix per error", err)
+	}
+	if w := serve(bad, "GET", "/ok"); w.Code != 404 {
+		t.Errorf("LoadTable with invalid routes registered /ok anyway")
+	}
+}
@@ -0,0 +1,59 @@
+package robo
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+var errNoFlusher = errors.New("robo: response writer does not support flushing")
+
+// SSEWriter sends server-sent events to a client.
+type SSEWriter struct {
+	w ResponseWriter
+	f http.Flusher
+}
+
+// NewSSEWriter prepares a response for streaming server-sent events, by
+// setting its Content-Type and asking proxies not to buffer it. It fails
+// if w can't be flushed, since events would then be held back.
+func NewSSEWriter(w ResponseWriter) (*SSEWriter, error) {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		return nil, errNoFlusher
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+
+	return &SSEWriter{w, f}, nil
+}
+
+// Send writes an event and flushes it to the client. The event name may
+// be empty, in which case the client treats it as a "message" event. Data
+// spanning several lines is sent as multiple data fields.
+func (s *SSEWriter) Send(event, data string) error {
+	var b strings.Builder
+
+	if event != "" {
+		b.WriteString("event: ")
+		b.WriteString(event)
+		b.WriteByte('\n')
+	}
+
+	for _, line := range strings.Split(data, "\n") {
+		b.WriteString("data: ")
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	b.WriteByte('\n')
+
+	if _, err := s.w.Write([]byte(b.String())); err != nil {
+		return err
+	}
+
+	s.f.Flush()
+	return nil
+}
@@ -0,0 +1,42 @@
+package robo
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSSEWriter(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	sse, err := NewSSEWriter(w)
+	if err != nil {
+		t.Fatalf("NewSSEWriter: %v", err)
+	}
+
+	if err := sse.Send("greeting", "hello"); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if !w.Flushed {
+		t.Errorf("Send didn't flush the response")
+	}
+	if err := sse.Send("", "two\nlines"); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	want := "event: greeting\ndata: hello\n\ndata: two\ndata: lines\n\n"
+	if w.Body.String() != want {
+		t.Errorf("body:")
+		t.Errorf("  got  %q", w.Body.String())
+		t.Errorf("  want %q", want)
+	}
+
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want %q", ct, "text/event-stream")
+	}
+
+	// hide the recorder's Flush method
+	plain := struct{ ResponseWriter }{httptest.NewRecorder()}
+	if _, err := NewSSEWriter(plain); err != errNoFlusher {
+		t.Errorf("NewSSEWriter(non-flusher) = %v, want %v", err, errNoFlusher)
+	}
+}
@@ -0,0 +1,73 @@
+package robo
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// The RouteStat type holds the counters kept for a route pattern when
+// statistics are enabled.
+type RouteStat struct {
+	Hits         uint64 // requests routed to the pattern
+	ClientErrors uint64 // responses with a 4xx status
+	ServerErrors uint64 // responses with a 5xx status
+}
+
+// routeStats holds per-pattern counters.
+type routeStats struct {
+	mu sync.Mutex
+	m  map[string]*routeCounters
+}
+
+type routeCounters struct {
+	hits, client, server atomic.Uint64
+}
+
+// EnableStats makes the Mux count how many requests each route pattern
+// serves, and how many of them fail. A request is attributed to the first
+// route it matches, even when that route yields to others by calling Next.
+func (m *Mux) EnableStats() {
+	m.update(func(t *table) {
+		t.stats = &routeStats{m: make(map[string]*routeCounters)}
+	})
+}
+
+// Stats returns a snapshot of the counters collected since EnableStats was
+// called, keyed by route pattern. It returns an empty map when statistics
+// aren't enabled.
+func (m *Mux) Stats() map[string]RouteStat {
+	stats := make(map[string]RouteStat)
+	rs := m.snapshot().stats
+	if rs == nil {
+		return stats
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	for pattern, c := range rs.m {
+		stats[pattern] = RouteStat{c.hits.Load(), c.client.Load(), c.server.Load()}
+	}
+
+	return stats
+}
+
+// record counts a request routed to pattern, and its response status.
+func (s *routeStats) record(pattern string, status int) {
+	s.mu.Lock()
+	c, ok := s.m[pattern]
+	if !ok {
+		c = new(routeCounters)
+		s.m[pattern] = c
+	}
+	s.mu.Unlock()
+
+	c.hits.Add(1)
+
+	switch {
+	case status >= 500:
+		c.server.Add(1)
+	case status >= 400:
+		c.client.Add(1)
+	}
+}
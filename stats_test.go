@@ -0,0 +1,40 @@
+package robo
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	handler := func(w ResponseWriter, r *Request) {
+		var code int
+		fmt.Sscan(r.Param("code"), &code)
+		w.WriteHeader(code)
+	}
+
+	mux := NewMux()
+	mux.Get("/status/{code}", handler)
+	mux.Get("/", func(w ResponseWriter, r *Request) {})
+
+	serve(mux, "GET", "/")
+	if stats := mux.Stats(); len(stats) != 0 {
+		t.Errorf("Stats() = %v before EnableStats, want an empty map", stats)
+	}
+
+	mux.EnableStats()
+	for _, path := range []string{"/", "/", "/status/200", "/status/404", "/status/410", "/status/503", "/missing"} {
+		serve(mux, "GET", path)
+	}
+
+	stats := mux.Stats()
+	want := map[string]RouteStat{
+		"/":              {2, 0, 0},
+		"/status/{code}": {4, 2, 1},
+	}
+
+	if fmt.Sprint(stats) != fmt.Sprint(want) {
+		t.Errorf("Stats():")
+		t.Errorf("  got  %v", stats)
+		t.Errorf("  want %v", want)
+	}
+}
@@ -0,0 +1,52 @@
+package robo
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// teeBody is a request body made up of a buffered prefix, followed by what
+// remains of the original body.
+type teeBody struct {
+	io.Reader
+	io.Closer
+}
+
+// TeeBody reads up to limit bytes of the request's body, and returns a
+// reader of them, for middleware which needs to inspect the body (for
+// example to log it) without consuming it. The request's body is replaced
+// so that handlers still read all of it, from the start. The second return
+// value reports whether the returned reader holds the whole body; for
+// bodies larger than limit it only holds their first limit bytes, while the
+// rest of the body is streamed to the handler as usual.
+func TeeBody(r *Request, limit int) (io.Reader, bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return bytes.NewReader(nil), true
+	}
+
+	buf, err := io.ReadAll(io.LimitReader(r.Body, int64(limit)+1))
+
+	if err == nil && len(buf) <= limit {
+		r.Body = teeBody{bytes.NewReader(buf), r.Body}
+		return bytes.NewReader(buf), true
+	}
+
+	r.Body = teeBody{io.MultiReader(bytes.NewReader(buf), r.Body), r.Body}
+	if len(buf) > limit {
+		buf = buf[:limit]
+	}
+	return bytes.NewReader(buf), false
+}
+
+// InspectBody returns a middleware handler which calls fn with up to limit
+// bytes of each request's body (see TeeBody) before calling Next. The
+// complete argument reports whether body holds all of it.
+func InspectBody(limit int, fn func(r *Request, body []byte, complete bool)) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		tee, complete := TeeBody(r, limit)
+		body, _ := io.ReadAll(tee)
+		fn(r, body, complete)
+		r.Next(w)
+	})
+}
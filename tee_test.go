@@ -0,0 +1,56 @@
+package robo
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInspectBody(t *testing.T) {
+	var seen string
+	var complete bool
+
+	mux := NewMux()
+	mux.Use(InspectBody(8, func(r *Request, body []byte, c bool) {
+		seen, complete = string(body), c
+	}))
+	mux.Any("/", func(w ResponseWriter, r *Request) {
+		buf, _ := io.ReadAll(r.Body)
+		w.Write(buf)
+	})
+
+	var tests = []struct {
+		body     string
+		seen     string
+		complete bool
+	}{
+		{"", "", true},
+		{"hello", "hello", true},
+		{"12345678", "12345678", true},
+		{"123456789", "12345678", false},
+		{strings.Repeat("x", 1000), "xxxxxxxx", false},
+	}
+
+	for _, test := range tests {
+		seen, complete = "-", false
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader(test.body)))
+
+		if seen != test.seen || complete != test.complete || w.Body.String() != test.body {
+			t.Errorf("POST / (%d bytes):", len(test.body))
+			t.Errorf("  got  tee %q (complete %v), handler read %d bytes", seen, complete, w.Body.Len())
+			t.Errorf("  want tee %q (complete %v), handler read %d bytes", test.seen, test.complete, len(test.body))
+		}
+	}
+}
+
+func TestTeeBodyNoBody(t *testing.T) {
+	r := &Request{Request: httptest.NewRequest("GET", "/", nil)}
+
+	tee, complete := TeeBody(r, 8)
+	if buf, _ := io.ReadAll(tee); len(buf) != 0 || !complete {
+		t.Errorf("TeeBody without a body = %q, %v; want \"\", true", buf, complete)
+	}
+}
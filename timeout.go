@@ -0,0 +1,124 @@
+package robo
+
+import (
+	"net/http"
+	"time"
+)
+
+// Timeout returns a middleware handler which limits the time the remaining
+// handlers may spend serving a request. If they haven't returned after d,
+// the client receives a 503 response, and further writes by the handlers
+// fail with http.ErrHandlerTimeout. The request's context is cancelled on
+// timeout, which long-running handlers should watch for.
+//
+// Timeout is implemented using http.TimeoutHandler, and has the same
+// limitations. The remaining handlers run in a goroutine of their own,
+// with copies of the request's data store and routing state (including
+// that of any Muxes the route's Mux is mounted in), so that handlers which
+// keep running after a timeout don't interfere with those which called
+// Next.
+// If they finish in time, the values they stored are copied back, and
+// calling Next continues from wherever they left off; otherwise it
+// continues with the next matching route.
+func Timeout(d time.Duration) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		var local *map[string]interface{}
+		if r.store != nil && *r.store != nil {
+			m := make(map[string]interface{}, len(**r.store))
+			for k, v := range **r.store {
+				m[k] = v
+			}
+			local = &m
+		}
+
+		// the remaining handlers get private copies of the queues of
+		// this and any parent Muxes, so that they can't advance the
+		// shared ones after a timeout
+		c := detach(r, &local)
+
+		done := make(chan bool)
+		next := http.HandlerFunc(func(w http.ResponseWriter, hr *http.Request) {
+			defer close(done)
+
+			rr := *c
+			rr.Request = hr
+			rr.Next(w)
+		})
+
+		http.TimeoutHandler(next, d, "Service unavailable.\n").ServeHTTP(w, r.Request)
+
+		select {
+		case <-done:
+			// pick up where the handlers left off
+			reattach(r, c)
+			if local != nil {
+				for k, v := range *local {
+					r.Set(k, v)
+				}
+			}
+		default:
+			// the handlers are still running, and own the rest of the
+			// route's chain
+			if r.queue != nil {
+				r.queue.handlers = nil
+			}
+		}
+	})
+}
+
+// detach returns a copy of r using store as its data store, with copies of
+// its queue and those of its parent Requests.
+func detach(r *Request, store **map[string]interface{}) *Request {
+	c := *r
+	c.store = store
+
+	if r.queue != nil {
+		q := *r.queue
+		q.store = store
+		if q.parent != nil {
+			q.parent = detach(q.parent, store)
+		}
+		c.queue = &q
+	}
+
+	return &c
+}
+
+// reattach copies the state of the queues of c, as returned by detach(r),
+// back to those of r and its parents, leaving their data stores alone.
+func reattach(r, c *Request) {
+	for ; r != nil && r.queue != nil; r, c = r.queue.parent, c.queue.parent {
+		q := *c.queue
+		q.store, q.parent = r.queue.store, r.queue.parent
+		*r.queue = q
+	}
+}
+
+// SlowRequestGuard returns a middleware handler which sets read and write
+// deadlines on the request's underlying connection before calling Next,
+// protecting the remaining handlers from clients which send or receive
+// data very slowly. A zero duration leaves the corresponding deadline
+// alone, as do ResponseWriters which don't support deadlines.
+func SlowRequestGuard(readTimeout, writeTimeout time.Duration) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		rc := http.NewResponseController(w)
+		now := time.Now()
+
+		if readTimeout > 0 {
+			rc.SetReadDeadline(now.Add(readTimeout))
+		}
+		if writeTimeout > 0 {
+			rc.SetWriteDeadline(now.Add(writeTimeout))
+		}
+
+		r.Next(w)
+	})
+}
+
+// Deadline returns the time by which the request should have been served,
+// as set by the Timeout middleware or the server, and whether there is
+// one at all. Handlers can use it to budget their work, for example by
+// shortening the timeouts of downstream calls.
+func Deadline(r *Request) (time.Time, bool) {
+	return r.Context().Deadline()
+}
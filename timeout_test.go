@@ -0,0 +1,199 @@
+package robo
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRouteTimeout(t *testing.T) {
+	slow := func(w ResponseWriter, r *Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+			w.Write([]byte("slow"))
+		}
+	}
+
+	mux := NewMux()
+	mux.Get("/slow", slow).Timeout(10 * time.Millisecond)
+	mux.Get("/fast", echo("/fast")).Timeout(time.Second)
+	mux.Get("/other", func(w ResponseWriter, r *Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("/other"))
+	})
+
+	var tests = []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/slow", 503, "Service unavailable.\n"},
+		{"/fast", 200, "/fast"},
+		{"/other", 200, "/other"},
+	}
+
+	for _, test := range tests {
+		w := serve(mux, "GET", test.path)
+		if w.Code != test.code || w.Body.String() != test.body {
+			t.Errorf("GET %s:", test.path)
+			t.Errorf("  got  %d %q", w.Code, w.Body.String())
+			t.Errorf("  want %d %q", test.code, test.body)
+		}
+	}
+}
+
+func TestRouteTimeoutIsolation(t *testing.T) {
+	finished := make(chan bool)
+
+	mux := NewMux()
+	mux.Use(func(w ResponseWriter, r *Request) {
+		r.Set("before", true)
+		r.Next(w)
+		r.Set("after", r.Get("handler"))
+		r.Next(w)
+	})
+	mux.Get("/slow", func(w ResponseWriter, r *Request) {
+		defer close(finished)
+		time.Sleep(30 * time.Millisecond)
+		r.Set("handler", "slow")
+		r.Next(w)
+	}).Timeout(10 * time.Millisecond)
+	mux.Get("/fast", func(w ResponseWriter, r *Request) {
+		r.Set("handler", "fast")
+		w.Write([]byte(fmt.Sprint(r.Get("before"))))
+	}).Timeout(time.Second)
+	mux.Get("/*", func(w ResponseWriter, r *Request) {
+		r.Set("fallthrough", true)
+	})
+
+	// run with -race to catch the timed out handler touching the request's
+	// data store or queue after the middleware has moved on
+	if w := serve(mux, "GET", "/slow"); w.Code != 503 {
+		t.Errorf("GET /slow: got %d, want 503", w.Code)
+	}
+	<-finished
+
+	var handler interface{}
+	mux.Always(func(w ResponseWriter, r *Request) {
+		r.Next(w)
+		handler = r.Get("after")
+	})
+
+	if w := serve(mux, "GET", "/fast"); w.Body.String() != "true" || handler != "fast" {
+		t.Errorf("GET /fast: got %q (stored %v), want %q (stored %q)", w.Body.String(), handler, "true", "fast")
+	}
+}
+
+func TestMountedRouteTimeoutIsolation(t *testing.T) {
+	finished := make(chan bool)
+
+	child := NewMux()
+	child.Get("/slow", func(w ResponseWriter, r *Request) {
+		defer close(finished)
+		time.Sleep(30 * time.Millisecond)
+		r.Next(w)
+	}).Timeout(10 * time.Millisecond)
+
+	var fellThrough interface{}
+
+	mux := NewMux()
+	mux.Use(func(w ResponseWriter, r *Request) {
+		r.Next(w)
+		fellThrough = r.Get("fallthrough")
+		r.Set("after", true)
+	})
+	mux.Mount("/child", child)
+	mux.Get("/*", func(w ResponseWriter, r *Request) {
+		r.Set("fallthrough", true)
+	})
+
+	// run with -race to catch the timed out handler falling through to the
+	// parent Mux, while the parent's middleware is still using the request
+	if w := serve(mux, "GET", "/child/slow"); w.Code != 503 {
+		t.Errorf("GET /child/slow: got %d, want 503", w.Code)
+	}
+	<-finished
+
+	if fellThrough != nil {
+		t.Errorf("parent middleware saw %v stored by a timed out handler", fellThrough)
+	}
+}
+
+// deadlineRecorder is a ResponseWriter recording the deadlines set on it.
+type deadlineRecorder struct {
+	*httptest.ResponseRecorder
+	read, write time.Time
+}
+
+func (w *deadlineRecorder) SetReadDeadline(t time.Time) error {
+	w.read = t
+	return nil
+}
+
+func (w *deadlineRecorder) SetWriteDeadline(t time.Time) error {
+	w.write = t
+	return nil
+}
+
+func TestSlowRequestGuard(t *testing.T) {
+	mux := NewMux()
+	mux.Use(SlowRequestGuard(5*time.Second, 0))
+	mux.Get("/", echo("/"))
+
+	w := &deadlineRecorder{ResponseRecorder: httptest.NewRecorder()}
+	start := time.Now()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if d := w.read.Sub(start); d < 5*time.Second || d > 6*time.Second {
+		t.Errorf("read deadline: got %v from now, want 5s", d)
+	}
+	if !w.write.IsZero() {
+		t.Errorf("write deadline: got %v, want none", w.write)
+	}
+	if w.Body.String() != "/" {
+		t.Errorf("body: got %q, want %q", w.Body.String(), "/")
+	}
+
+	// writers without deadline support are served regardless
+	if rw := serve(mux, "GET", "/"); rw.Code != 200 {
+		t.Errorf("GET / without deadline support: got %d, want 200", rw.Code)
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	var deadline time.Time
+	var ok bool
+	remember := func(w ResponseWriter, r *Request) {
+		deadline, ok = Deadline(r)
+	}
+
+	mux := NewMux()
+	mux.Get("/limited", remember).Timeout(time.Minute)
+	mux.Get("/unlimited", remember)
+
+	start := time.Now()
+
+	serve(mux, "GET", "/limited")
+	if !ok || deadline.Before(start.Add(time.Minute)) || deadline.After(time.Now().Add(time.Minute)) {
+		t.Errorf("GET /limited: Deadline = %v, %v, want about a minute from now", deadline, ok)
+	}
+
+	serve(mux, "GET", "/unlimited")
+	if ok || !deadline.IsZero() {
+		t.Errorf("GET /unlimited: Deadline = %v, %v, want no deadline", deadline, ok)
+	}
+
+	// deadlines set elsewhere are reported too
+	ctx, cancel := context.WithDeadline(context.Background(), start.Add(time.Hour))
+	defer cancel()
+
+	hr := httptest.NewRequest("GET", "/unlimited", nil).WithContext(ctx)
+	mux.ServeHTTP(httptest.NewRecorder(), hr)
+
+	if !ok || !deadline.Equal(start.Add(time.Hour)) {
+		t.Errorf("GET /unlimited (with context deadline): Deadline = %v, %v, want %v", deadline, ok, start.Add(time.Hour))
+	}
+}
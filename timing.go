@@ -0,0 +1,81 @@
+package robo
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// serverTimingKey is the data store key of a request's *ServerTimings.
+const serverTimingKey = "robo.timing"
+
+// ServerTimings collects metrics for a request's Server-Timing header.
+// A nil *ServerTimings ignores all metrics.
+type ServerTimings struct {
+	mu      sync.Mutex
+	metrics []string
+}
+
+// ServerTimingHeader returns a middleware handler which lets the remaining
+// handlers record metrics through ServerTiming, and writes them to the
+// Server-Timing response header right before it is committed.
+func ServerTimingHeader() Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		st := new(ServerTimings)
+		r.Set(serverTimingKey, st)
+
+		hw := newHookWriter(w, func() {
+			if v := st.String(); v != "" {
+				w.Header().Add("Server-Timing", v)
+			}
+		})
+
+		r.Next(hw.writer())
+		hw.commit(200)
+	})
+}
+
+// ServerTiming returns the request's metric collection, or nil if the
+// ServerTimingHeader middleware isn't in use.
+func ServerTiming(r *Request) *ServerTimings {
+	st, _ := r.Get(serverTimingKey).(*ServerTimings)
+	return st
+}
+
+// Add records a metric with a duration (omitted if negative) and an
+// optional description.
+func (st *ServerTimings) Add(name string, dur time.Duration, desc string) {
+	if st == nil {
+		return
+	}
+
+	metric := name
+	if dur >= 0 {
+		ms := float64(dur) / float64(time.Millisecond)
+		metric += ";dur=" + strconv.FormatFloat(ms, 'f', -1, 64)
+	}
+	if desc != "" {
+		metric += ";desc=" + strconv.Quote(desc)
+	}
+
+	st.mu.Lock()
+	st.metrics = append(st.metrics, metric)
+	st.mu.Unlock()
+}
+
+// Since records a metric with the time elapsed since start.
+func (st *ServerTimings) Since(name string, start time.Time) {
+	st.Add(name, time.Since(start), "")
+}
+
+// String formats the recorded metrics as a Server-Timing header value.
+func (st *ServerTimings) String() string {
+	if st == nil {
+		return ""
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return strings.Join(st.metrics, ", ")
+}
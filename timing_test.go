@@ -0,0 +1,35 @@
+package robo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestServerTiming(t *testing.T) {
+	mux := NewMux()
+	mux.Use(ServerTimingHeader(), func(w ResponseWriter, r *Request) {
+		ServerTiming(r).Add("db", 53*time.Millisecond+200*time.Microsecond, "")
+		r.Next(w)
+	})
+	mux.Get("/", func(w ResponseWriter, r *Request) {
+		ServerTiming(r).Add("cache", -1, "hit")
+		w.Write([]byte("hello"))
+	})
+
+	want := `db;dur=53.2, cache;desc="hit"`
+	if got := serve(mux, "GET", "/").Header().Get("Server-Timing"); got != want {
+		t.Errorf("Server-Timing:")
+		t.Errorf("  got  %s", got)
+		t.Errorf("  want %s", want)
+	}
+
+	// without the middleware, metrics are silently dropped
+	plain := NewMux()
+	plain.Get("/", func(w ResponseWriter, r *Request) {
+		ServerTiming(r).Add("db", time.Millisecond, "")
+	})
+
+	if got := serve(plain, "GET", "/").Header().Get("Server-Timing"); got != "" {
+		t.Errorf("Server-Timing without middleware: got %q, want none", got)
+	}
+}
@@ -0,0 +1,48 @@
+package robo
+
+import (
+	"net/http"
+)
+
+// TraceHooks holds functions called by a Mux at various points while
+// dispatching a request, for observability and debugging. Any of them may
+// be nil. Unlike middleware, the hooks can't affect how a request is
+// served.
+type TraceHooks struct {
+	// OnMatch is called when a route matches the request, including
+	// routes reached by calls to Next, with the route's pattern.
+	OnMatch func(r *http.Request, pattern string)
+
+	// OnHandlerStart and OnHandlerEnd are called before and after each
+	// handler serving the request, including middleware and failure
+	// handlers. ChainDepth and PatternFromRequest tell them apart.
+	OnHandlerStart func(r *Request)
+	OnHandlerEnd   func(r *Request)
+
+	// OnNotFound is called when the request can't be routed, before any
+	// failure handlers are invoked, with a description of the failure.
+	// Mounted Muxes without failure handlers of their own leave this to
+	// their parent.
+	OnNotFound func(r *http.Request, f *RoutingFailure)
+}
+
+// Trace installs hooks to be called while the Mux dispatches requests.
+// Requests routed through mounted Muxes are traced by each Mux's own
+// hooks, if any.
+func (m *Mux) Trace(hooks TraceHooks) {
+	m.update(func(t *table) {
+		t.trace = &hooks
+	})
+}
+
+func (th *TraceHooks) handlerStart(r *Request) {
+	if th.OnHandlerStart != nil {
+		th.OnHandlerStart(r)
+	}
+}
+
+func (th *TraceHooks) handlerEnd(r *Request) {
+	if th.OnHandlerEnd != nil {
+		th.OnHandlerEnd(r)
+	}
+}
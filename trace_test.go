@@ -0,0 +1,63 @@
+package robo
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	var log []string
+
+	mux := NewMux()
+	mux.Trace(TraceHooks{
+		OnMatch: func(r *http.Request, pattern string) {
+			log = append(log, "match "+pattern)
+		},
+		OnHandlerStart: func(r *Request) {
+			log = append(log, fmt.Sprintf("start %d", ChainDepth(r)))
+		},
+		OnHandlerEnd: func(r *Request) {
+			log = append(log, fmt.Sprintf("end %d", ChainDepth(r)))
+		},
+		OnNotFound: func(r *http.Request, f *RoutingFailure) {
+			log = append(log, fmt.Sprintf("failure %d", f.Status))
+		},
+	})
+	mux.Get("/{id}", trace(&log, "a"), trace(&log, "b"))
+	mux.Get("/x", trace(&log, "c"))
+	mux.NotFound(func(w ResponseWriter, r *Request) {
+		log = append(log, "not found")
+	})
+
+	var tests = []struct {
+		path string
+		log  []string
+	}{
+		{"/x", []string{
+			"match /{id}",
+			"start 1", "a",
+			"start 2", "b",
+			"match /x",
+			"start 1", "c",
+			"failure 404",
+			"start 1", "not found", "end 1",
+			"end 1", "end 2", "end 1",
+		}},
+		{"/a/b", []string{
+			"failure 404",
+			"start 1", "not found", "end 1",
+		}},
+	}
+
+	for _, test := range tests {
+		log = nil
+		serve(mux, "GET", test.path)
+
+		if fmt.Sprint(log) != fmt.Sprint(test.log) {
+			t.Errorf("GET %s:", test.path)
+			t.Errorf("  got  %q", log)
+			t.Errorf("  want %q", test.log)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package robo
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// hookWriter wraps a ResponseWriter, calling a function right before the
+// response header is committed. It is handed to other handlers through the
+// writer method, so that they see the same optional interfaces as on the
+// wrapped ResponseWriter.
+type hookWriter struct {
+	ResponseWriter
+	before func()
+	status int
+}
+
+// newHookWriter wraps w, calling before right before the response header
+// is committed.
+func newHookWriter(w ResponseWriter, before func()) *hookWriter {
+	return &hookWriter{ResponseWriter: w, before: before}
+}
+
+// commit runs the hook, unless the header has already been committed.
+func (w *hookWriter) commit(code int) {
+	if w.status == 0 {
+		w.status = code
+		if w.before != nil {
+			w.before()
+		}
+	}
+}
+
+func (w *hookWriter) WriteHeader(code int) {
+	// informational responses don't commit the final header
+	if code >= 200 || code == 101 {
+		w.commit(code)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *hookWriter) Write(buf []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(200)
+	}
+	return w.ResponseWriter.Write(buf)
+}
+
+// Unwrap returns the wrapped ResponseWriter, for http.ResponseController.
+func (w *hookWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// writer returns w as a ResponseWriter which also implements http.Flusher
+// and http.Hijacker, if (and only if) the wrapped ResponseWriter does.
+func (w *hookWriter) writer() ResponseWriter {
+	_, flusher := w.ResponseWriter.(http.Flusher)
+	_, hijacker := w.ResponseWriter.(http.Hijacker)
+
+	switch {
+	case flusher && hijacker:
+		return flushHijackWriter{w}
+	case flusher:
+		return flushWriter{w}
+	case hijacker:
+		return hijackWriter{w}
+	}
+	return w
+}
+
+// flushWriter is a hookWriter for a ResponseWriter which can be flushed.
+type flushWriter struct {
+	*hookWriter
+}
+
+func (w flushWriter) Flush() {
+	w.flush()
+}
+
+// hijackWriter is a hookWriter for a ResponseWriter which can be hijacked.
+type hijackWriter struct {
+	*hookWriter
+}
+
+func (w hijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.hijack()
+}
+
+// flushHijackWriter is a hookWriter for a ResponseWriter which can be both
+// flushed and hijacked.
+type flushHijackWriter struct {
+	*hookWriter
+}
+
+func (w flushHijackWriter) Flush() {
+	w.flush()
+}
+
+func (w flushHijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.hijack()
+}
+
+// flush commits the header, if needed, and flushes the wrapped writer.
+func (w *hookWriter) flush() {
+	if w.status == 0 {
+		w.WriteHeader(200)
+	}
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+// hijack takes over the wrapped writer's connection.
+func (w *hookWriter) hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
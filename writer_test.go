@@ -0,0 +1,85 @@
+package robo
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// hijackRecorder is a ResponseRecorder which can also be hijacked.
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (w *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.hijacked = true
+	return nil, nil, nil
+}
+
+func TestHookWriterInterfaces(t *testing.T) {
+	var flusher, hijacker bool
+
+	mux := NewMux()
+	mux.Use(After(func(r *Request, status int) {}))
+	mux.Get("/", func(w ResponseWriter, r *Request) {
+		_, flusher = w.(http.Flusher)
+		if h, ok := w.(http.Hijacker); ok {
+			hijacker = true
+			h.Hijack()
+		}
+	})
+
+	var tests = []struct {
+		w        http.ResponseWriter
+		flusher  bool
+		hijacker bool
+	}{
+		{httptest.NewRecorder(), true, false},
+		{struct{ http.ResponseWriter }{httptest.NewRecorder()}, false, false},
+		{&hijackRecorder{ResponseRecorder: httptest.NewRecorder()}, true, true},
+		{struct {
+			http.ResponseWriter
+			http.Hijacker
+		}{httptest.NewRecorder(), &hijackRecorder{}}, false, true},
+	}
+
+	for _, test := range tests {
+		flusher, hijacker = false, false
+		mux.ServeHTTP(test.w, httptest.NewRequest("GET", "/", nil))
+
+		if flusher != test.flusher || hijacker != test.hijacker {
+			t.Errorf("%T:", test.w)
+			t.Errorf("  got  flusher %v, hijacker %v", flusher, hijacker)
+			t.Errorf("  want flusher %v, hijacker %v", test.flusher, test.hijacker)
+		}
+	}
+
+	hr := &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+	mux.ServeHTTP(hr, httptest.NewRequest("GET", "/", nil))
+	if !hr.hijacked {
+		t.Errorf("Hijack wasn't passed on to the wrapped writer")
+	}
+}
+
+func TestHookWriterSSE(t *testing.T) {
+	var err error
+
+	mux := NewMux()
+	mux.Use(After(func(r *Request, status int) {}))
+	mux.Get("/", func(w ResponseWriter, r *Request) {
+		_, err = NewSSEWriter(w)
+	})
+
+	mux.ServeHTTP(struct{ http.ResponseWriter }{httptest.NewRecorder()}, httptest.NewRequest("GET", "/", nil))
+	if err != errNoFlusher {
+		t.Errorf("NewSSEWriter(non-flusher) = %v, want %v", err, errNoFlusher)
+	}
+
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if err != nil {
+		t.Errorf("NewSSEWriter(flusher) = %v, want nil", err)
+	}
+}